@@ -0,0 +1,551 @@
+// Command modbus runs single Modbus RTU requests from the command line
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btolarz/max485-raspberry-nodejs/go/modbus"
+)
+
+// parseDirectionFlags interprets the -de and -re flags. -de takes a pin,
+// "rts" or "none"; -re takes a pin, or "tied" (or the -de pin) when one GPIO
+// drives both DE and /RE. A leading "!" inverts a line's polarity.
+func parseDirectionFlags(de, re string) (cfg modbus.DirectionConfig, deLine, reLine string) {
+	if strings.HasPrefix(de, "!") {
+		cfg.InvertDE = true
+		de = de[1:]
+	}
+	if strings.HasPrefix(re, "!") {
+		cfg.InvertRE = true
+		re = re[1:]
+	}
+
+	switch {
+	case de == "none":
+		cfg.Mode = modbus.DirectionNone
+	case de == "rts":
+		cfg.Mode = modbus.DirectionRTS
+	case re == "tied" || re == de:
+		cfg.Mode = modbus.DirectionTied
+	default:
+		cfg.Mode = modbus.DirectionSplit
+	}
+	return cfg, de, re
+}
+
+// parseValues parses the -values flag
+func parseValues(s string) []uint16 {
+	var values []uint16
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(field), 0, 16)
+		if err != nil {
+			log.Fatalf("Invalid -values %q: %v", s, err)
+		}
+		values = append(values, uint16(v))
+	}
+	return values
+}
+
+// fileProgress reports the progress of file-get and file-put on stderr
+func fileProgress(done, total int) {
+	fmt.Fprintf(os.Stderr, "\r%d/%d registers", done, total)
+	if done == total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// deviceIDLevels maps the -level flag to read device ID codes
+var deviceIDLevels = map[string]modbus.DeviceIDCode{
+	"basic":      modbus.DeviceIDBasic,
+	"regular":    modbus.DeviceIDRegular,
+	"extended":   modbus.DeviceIDExtended,
+	"individual": modbus.DeviceIDIndividual,
+}
+
+// objectNames names the identification objects the specification defines
+var objectNames = map[byte]string{
+	modbus.ObjectVendorName:          "VendorName",
+	modbus.ObjectProductCode:         "ProductCode",
+	modbus.ObjectMajorMinorRevision:  "MajorMinorRevision",
+	modbus.ObjectVendorURL:           "VendorUrl",
+	modbus.ObjectProductName:         "ProductName",
+	modbus.ObjectModelName:           "ModelName",
+	modbus.ObjectUserApplicationName: "UserApplicationName",
+}
+
+// loopbackPattern is the data diag sends in its Return Query Data test,
+// alternating bits so stuck or swapped lines show up
+var loopbackPattern = []byte{0xA5, 0x5A, 0x0F, 0xF0}
+
+// commEventFlags lists the flags set in a comm event log entry
+func commEventFlags(e modbus.CommEvent) string {
+	var flags string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{e.CommError, "comm error"},
+		{e.CharacterOverrun, "character overrun"},
+		{e.Broadcast, "broadcast"},
+		{e.ReadException, "read exception"},
+		{e.AbortException, "abort exception"},
+		{e.BusyException, "busy exception"},
+		{e.NAKException, "NAK exception"},
+		{e.WriteTimeout, "write timeout"},
+		{e.ListenOnly, "listen only"},
+	} {
+		if f.set {
+			flags += ", " + f.name
+		}
+	}
+	return flags
+}
+
+// checkFlag exits when the integer flag name is outside min to max, before
+// it is truncated to the width of its protocol field
+func checkFlag(name string, value, min, max int) {
+	if value < min || value > max {
+		log.Fatalf("Invalid -%s %d: must be %d to %d", name, value, min, max)
+	}
+}
+
+// exitStatus gives each class of failed request its own exit status, so
+// scripts can tell a rejected request from a silent slave or a noisy bus.
+// Everything else, such as bad flags, exits with 1.
+var exitStatus = map[modbus.ModbusError]int{
+	modbus.ModbusExceptionResponse: 2,
+	modbus.ModbusTimeoutError:      3,
+	modbus.ModbusCRCError:          4,
+	modbus.ModbusInvalidResponse:   5,
+	modbus.ModbusSerialError:       6,
+	modbus.ModbusInvalidRequest:    7,
+	modbus.ModbusCanceled:          130,
+}
+
+// fatal reports a failed command and exits with the status for its class
+func fatal(action string, err error) {
+	var exc *modbus.ExceptionError
+	var mismatch *modbus.MismatchError
+	var modbusErr *modbus.Error
+	switch {
+	case errors.As(err, &exc):
+		log.Printf("Failed to %s: slave %d returned exception %d (%s)", action, exc.SlaveID, byte(exc.Code), exc.Code)
+	case errors.As(err, &mismatch):
+		// The message already shows both frames
+		log.Printf("Failed to %s: %v", action, err)
+	case errors.As(err, &modbusErr) && len(modbusErr.Frame) > 0:
+		log.Printf("Failed to %s: %v (response % X)", action, err, modbusErr.Frame)
+	default:
+		log.Printf("Failed to %s: %v", action, err)
+	}
+
+	status, ok := exitStatus[modbus.ErrorCode(err)]
+	if !ok {
+		status = 1
+	}
+	os.Exit(status)
+}
+
+func main() {
+	// Parse command line arguments
+	port := flag.String("port", "/dev/ttyUSB0", "Serial port")
+	baudRate := flag.Int("baud", 9600, "Baud rate")
+	parity := flag.String("parity", "N", "Parity: N, E or O")
+	dataBits := flag.Int("databits", 8, "Data bits")
+	stopBits := flag.Int("stopbits", 1, "Stop bits: 1 or 2")
+	timeout := flag.Duration("timeout", modbus.DefaultResponseTimeout, "Response timeout")
+	charTimeout := flag.Duration("char-timeout", 0, "Inter-character timeout (default: t1.5 plus slack)")
+	echo := flag.Bool("echo", false, "Read back and check each request the transceiver echoes")
+	turnaround := flag.Duration("turnaround", 0, "Quiet time after a broadcast write (default: 100ms)")
+	dePin := flag.String("de", "17", "DE pin number (line name with -gpiochip), \"rts\" or \"none\"; prefix ! for active-low")
+	rePin := flag.String("re", "27", "RE pin number (line name with -gpiochip) or \"tied\"; prefix ! for inverted")
+	dirSetup := flag.Duration("dir-setup", 0, "Delay after enabling the driver before sending")
+	dirHold := flag.Duration("dir-hold", 0, "Delay after sending before releasing the driver")
+	gpioChip := flag.String("gpiochip", "", "GPIO character device, e.g. /dev/gpiochip0 (default: go-rpio)")
+	command := flag.String("cmd", "", "Command to execute")
+	slaveID := flag.Int("slave", 1, "Slave ID, 0 to broadcast a write")
+	startAddr := flag.Int("addr", 0, "Starting address")
+	count := flag.Int("count", 1, "Count")
+	value := flag.Int("value", 0, "Value to write")
+	values := flag.String("values", "", "Comma-separated register values to write, e.g. 1,0x20,300")
+	writeAddr := flag.Int("write-addr", 0, "Starting address of the write for readwrite")
+	idLevel := flag.String("level", "basic", "Objects for identify: basic, regular, extended or individual")
+	objectID := flag.Int("object", 0, "First object for identify, or the only one at level individual")
+	andMask := flag.Int("and", 0xFFFF, "AND mask for mask_write")
+	orMask := flag.Int("or", 0, "OR mask for mask_write")
+	bit := flag.Int("bit", 0, "Register bit for set_bit, clear_bit and toggle_bit, 0 being the least significant")
+	fileNumber := flag.Int("file", 1, "File number for file-get and file-put")
+	record := flag.Int("record", 0, "First record for file-get and file-put")
+	path := flag.String("path", "", "Local file that file-get writes and file-put reads, registers big-endian")
+	chunk := flag.Int("chunk", 0, "Registers per transaction for file-get and file-put (default: as many as fit)")
+	maskFallback := flag.Bool("mask-fallback", false, "Read and write the whole register for slaves without function 0x16")
+	retries := flag.Int("retries", 0, "Retries after a timeout or CRC error")
+	retryBackoff := flag.Duration("retry-backoff", 0, "Delay before the first retry, doubled for each further one")
+	retryWrites := flag.Bool("retry-writes", false, "Retry writes too (only for idempotent writes)")
+	rs485 := flag.Bool("rs485", false, "Use kernel RS-485 mode instead of the DE/RE pins")
+	rs485RTSLow := flag.Bool("rs485-rts-low", false, "Drive RTS low while sending (kernel RS-485 mode)")
+	rs485DelayBefore := flag.Duration("rs485-delay-before", 0, "Delay between RTS and the first bit (kernel RS-485 mode)")
+	rs485DelayAfter := flag.Duration("rs485-delay-after", 0, "Delay between the last bit and releasing RTS (kernel RS-485 mode)")
+	rs485RXDuringTX := flag.Bool("rs485-rx-during-tx", false, "Keep the receiver enabled while sending (kernel RS-485 mode)")
+	flag.Parse()
+
+	dirCfg, deLine, reLine := parseDirectionFlags(*dePin, *rePin)
+	dirCfg.Setup = *dirSetup
+	dirCfg.Hold = *dirHold
+	checkFlag("slave", *slaveID, modbus.BroadcastID, modbus.MaxSlaveID)
+	checkFlag("addr", *startAddr, 0, 0xFFFF)
+	checkFlag("count", *count, 1, 0xFFFF)
+	checkFlag("value", *value, 0, 0xFFFF)
+	checkFlag("write-addr", *writeAddr, 0, 0xFFFF)
+	checkFlag("object", *objectID, 0, 0xFF)
+	checkFlag("and", *andMask, 0, 0xFFFF)
+	checkFlag("or", *orMask, 0, 0xFFFF)
+	checkFlag("bit", *bit, 0, 15)
+	checkFlag("file", *fileNumber, 1, 0xFFFF)
+	checkFlag("record", *record, 0, modbus.MaxFileRecordNumber)
+	lineParity, err := modbus.ParseParity(*parity)
+	if err != nil {
+		log.Fatalf("Invalid -parity: %v", err)
+	}
+	opts := []modbus.DeviceOption{
+		modbus.WithDirectionConfig(dirCfg),
+		modbus.WithSerialOptions(modbus.SerialOptions{
+			DataBits:         *dataBits,
+			Parity:           lineParity,
+			StopBits:         *stopBits,
+			ResponseTimeout:  *timeout,
+			InterCharTimeout: *charTimeout,
+			LocalEcho:        *echo,
+		}),
+		modbus.WithRetryPolicy(modbus.RetryPolicy{
+			MaxAttempts: *retries + 1,
+			Backoff:     *retryBackoff,
+			RetryWrites: *retryWrites,
+		}),
+		modbus.WithTiming(modbus.Timing{Turnaround: *turnaround}),
+	}
+
+	if *maskFallback {
+		opts = append(opts, modbus.WithMaskWriteFallback())
+	}
+
+	de, re := 0, 0
+	usesPins := !*rs485 && (dirCfg.Mode == modbus.DirectionSplit || dirCfg.Mode == modbus.DirectionTied)
+	if *gpioChip != "" {
+		opts = append(opts, modbus.WithGPIOChip(*gpioChip, deLine, reLine))
+	} else if usesPins {
+		if de, err = strconv.Atoi(deLine); err != nil {
+			log.Fatalf("Invalid DE pin %q: line names need -gpiochip", deLine)
+		}
+		if dirCfg.Mode == modbus.DirectionSplit {
+			if re, err = strconv.Atoi(reLine); err != nil {
+				log.Fatalf("Invalid RE pin %q: line names need -gpiochip", reLine)
+			}
+		}
+	}
+	if *rs485 {
+		opts = append(opts, modbus.WithRS485(modbus.RS485Config{
+			Enabled:         true,
+			RTSActiveLow:    *rs485RTSLow,
+			DelayBeforeSend: *rs485DelayBefore,
+			DelayAfterSend:  *rs485DelayAfter,
+			RXDuringTX:      *rs485RXDuringTX,
+		}))
+	}
+
+	// Create Modbus device
+	device, err := modbus.NewModbusDevice(*port, *baudRate, de, re, opts...)
+	if err != nil {
+		log.Fatalf("Failed to create Modbus device: %v", err)
+	}
+	defer device.Close()
+
+	// Ctrl-C abandons the transaction in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Execute command
+	switch *command {
+	case "read_coils":
+		values, err := device.ReadCoilsContext(ctx, byte(*slaveID), uint16(*startAddr), uint16(*count))
+		if err != nil {
+			fatal("read coils", err)
+		}
+		for i, v := range values {
+			fmt.Printf("Coil[%d] = %v\n", i, v)
+		}
+
+	case "read_discrete":
+		values, err := device.ReadDiscreteInputsContext(ctx, byte(*slaveID), uint16(*startAddr), uint16(*count))
+		if err != nil {
+			fatal("read discrete inputs", err)
+		}
+		for i, v := range values {
+			fmt.Printf("Input[%d] = %v\n", i, v)
+		}
+
+	case "read_holdreg":
+		values, err := device.ReadHoldingRegistersContext(ctx, byte(*slaveID), uint16(*startAddr), uint16(*count))
+		if err != nil {
+			fatal("read holding registers", err)
+		}
+		for i, v := range values {
+			fmt.Printf("Reg[%d] = %d\n", i, v)
+		}
+
+	case "read_inputreg":
+		values, err := device.ReadInputRegistersContext(ctx, byte(*slaveID), uint16(*startAddr), uint16(*count))
+		if err != nil {
+			fatal("read input registers", err)
+		}
+		for i, v := range values {
+			fmt.Printf("Reg[%d] = %d\n", i, v)
+		}
+
+	case "write_coil":
+		err := device.WriteCoilContext(ctx, byte(*slaveID), uint16(*startAddr), *value != 0)
+		if err != nil {
+			fatal("write coil", err)
+		}
+
+	case "write_register":
+		err := device.WriteRegisterContext(ctx, byte(*slaveID), uint16(*startAddr), uint16(*value))
+		if err != nil {
+			fatal("write register", err)
+		}
+
+	case "readwrite":
+		regs, err := device.ReadWriteMultipleRegistersContext(ctx, byte(*slaveID), uint16(*startAddr), uint16(*count),
+			uint16(*writeAddr), parseValues(*values))
+		if err != nil {
+			fatal("read/write registers", err)
+		}
+		for i, v := range regs {
+			fmt.Printf("Reg[%d] = %d\n", i, v)
+		}
+
+	case "identify":
+		code, ok := deviceIDLevels[*idLevel]
+		if !ok {
+			log.Fatalf("Invalid -level %q: must be basic, regular, extended or individual", *idLevel)
+		}
+		objects, err := device.ReadDeviceIdentificationContext(ctx, byte(*slaveID), code, byte(*objectID))
+		if err != nil {
+			fatal("read device identification", err)
+		}
+		ids := make([]int, 0, len(objects))
+		for id := range objects {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			name := objectNames[byte(id)]
+			if name == "" {
+				name = fmt.Sprintf("Object 0x%02X", id)
+			}
+			fmt.Printf("%s = %q\n", name, objects[byte(id)])
+		}
+
+	case "mask_write":
+		err := device.MaskWriteRegisterContext(ctx, byte(*slaveID), uint16(*startAddr), uint16(*andMask), uint16(*orMask))
+		if err != nil {
+			fatal("mask write register", err)
+		}
+
+	case "set_bit":
+		err := device.SetBitContext(ctx, byte(*slaveID), uint16(*startAddr), *bit)
+		if err != nil {
+			fatal("set bit", err)
+		}
+
+	case "clear_bit":
+		err := device.ClearBitContext(ctx, byte(*slaveID), uint16(*startAddr), *bit)
+		if err != nil {
+			fatal("clear bit", err)
+		}
+
+	case "toggle_bit":
+		set, err := device.ToggleBitContext(ctx, byte(*slaveID), uint16(*startAddr), *bit)
+		if err != nil {
+			fatal("toggle bit", err)
+		}
+		fmt.Printf("Bit[%d] = %v\n", *bit, set)
+
+	case "diag":
+		if err := device.ReturnQueryDataContext(ctx, byte(*slaveID), loopbackPattern); err != nil {
+			fatal("run loopback test", err)
+		}
+		fmt.Printf("Loopback % X: OK\n", loopbackPattern)
+		counters, err := device.ReadDiagnosticCountersContext(ctx, byte(*slaveID))
+		if err != nil {
+			fatal("read diagnostic counters", err)
+		}
+		for _, c := range []struct {
+			name  string
+			value uint16
+		}{
+			{"Bus messages", counters.BusMessages},
+			{"Bus CRC errors", counters.BusCommErrors},
+			{"Bus exception errors", counters.BusExceptionErrors},
+			{"Server messages", counters.ServerMessages},
+			{"Server no responses", counters.ServerNoResponses},
+			{"Server NAKs", counters.ServerNAKs},
+			{"Server busy", counters.ServerBusy},
+			{"Bus character overruns", counters.BusCharacterOverruns},
+		} {
+			fmt.Printf("%-24s %5d\n", c.name, c.value)
+		}
+
+	case "exception_status":
+		status, err := device.ReadExceptionStatusContext(ctx, byte(*slaveID))
+		if err != nil {
+			fatal("read exception status", err)
+		}
+		fmt.Printf("Exception status = 0x%02X (%08b)\n", status, status)
+
+	case "comm_counter":
+		counter, err := device.GetCommEventCounterContext(ctx, byte(*slaveID))
+		if err != nil {
+			fatal("get comm event counter", err)
+		}
+		fmt.Printf("Events = %d\nBusy = %v\n", counter.EventCount, counter.Busy)
+
+	case "comm_log":
+		eventLog, err := device.GetCommEventLogContext(ctx, byte(*slaveID))
+		if err != nil {
+			fatal("get comm event log", err)
+		}
+		fmt.Printf("Events = %d\nMessages = %d\nBusy = %v\n", eventLog.EventCount, eventLog.MessageCount, eventLog.Busy)
+		for i, e := range eventLog.Events {
+			fmt.Printf("Event[%d] = 0x%02X %s%s\n", i, e.Raw, e.Kind, commEventFlags(e))
+		}
+
+	case "server_id":
+		id, err := device.ReportServerIDContext(ctx, byte(*slaveID))
+		if err != nil {
+			fatal("report server ID", err)
+		}
+		fmt.Printf("Server ID = 0x%02X\nRunning = %v\nAdditional data = % X %q\n", id.ID, id.Running, id.AdditionalData, id.AdditionalData)
+
+	case "file-get":
+		data, err := device.DownloadFileContext(ctx, byte(*slaveID), uint16(*fileNumber), uint16(*record), *count,
+			modbus.FileTransferOptions{ChunkSize: *chunk, Progress: fileProgress})
+		if err != nil {
+			fatal("read file", err)
+		}
+		if *path == "" {
+			for i, v := range data {
+				fmt.Printf("Record[%d] = %d\n", *record+i, v)
+			}
+			break
+		}
+		raw := make([]byte, 0, 2*len(data))
+		for _, v := range data {
+			raw = binary.BigEndian.AppendUint16(raw, v)
+		}
+		if err := os.WriteFile(*path, raw, 0o644); err != nil {
+			log.Fatalf("Failed to write %s: %v", *path, err)
+		}
+
+	case "file-put":
+		var data []uint16
+		if *path == "" {
+			data = parseValues(*values)
+		} else {
+			raw, err := os.ReadFile(*path)
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", *path, err)
+			}
+			if len(raw)%2 != 0 {
+				log.Fatalf("Invalid %s: %d bytes is not a whole number of registers", *path, len(raw))
+			}
+			for i := 0; i < len(raw); i += 2 {
+				data = append(data, binary.BigEndian.Uint16(raw[i:]))
+			}
+		}
+		err := device.UploadFileContext(ctx, byte(*slaveID), uint16(*fileNumber), uint16(*record), data,
+			modbus.FileTransferOptions{ChunkSize: *chunk, Progress: fileProgress})
+		if err != nil {
+			fatal("write file", err)
+		}
+
+	default:
+		fmt.Println("Usage:")
+		fmt.Println("  read_coils   - Read coils")
+		fmt.Println("  read_discrete - Read discrete inputs")
+		fmt.Println("  read_holdreg  - Read holding registers")
+		fmt.Println("  read_inputreg - Read input registers")
+		fmt.Println("  write_coil    - Write single coil")
+		fmt.Println("  write_register - Write single register")
+		fmt.Println("  readwrite     - Write -values from -write-addr, then read -count registers from -addr")
+		fmt.Println("  identify      - Read device identification at -level from -object")
+		fmt.Println("  mask_write    - Change register bits: (reg AND -and) OR (-or AND NOT -and)")
+		fmt.Println("  set_bit       - Set register bit -bit")
+		fmt.Println("  clear_bit     - Clear register bit -bit")
+		fmt.Println("  toggle_bit    - Invert register bit -bit")
+		fmt.Println("  diag          - Run a loopback test and print the diagnostic counters")
+		fmt.Println("  exception_status - Read the exception status outputs")
+		fmt.Println("  comm_counter  - Get the comm event counter")
+		fmt.Println("  comm_log      - Get the comm event log, most recent event first")
+		fmt.Println("  server_id     - Report the server ID and run status")
+		fmt.Println("  file-get      - Read -count records of -file from -record into -path, or print them")
+		fmt.Println("  file-put      - Write -path, or -values, to -file from -record")
+		fmt.Println("\nRequired flags:")
+		fmt.Println("  -port <port>     - Serial port (default: /dev/ttyUSB0)")
+		fmt.Println("  -baud <rate>     - Baud rate (default: 9600)")
+		fmt.Println("  -parity <p>      - Parity N, E or O (default: N)")
+		fmt.Println("  -databits <n>    - Data bits (default: 8)")
+		fmt.Println("  -stopbits <n>    - Stop bits 1 or 2 (default: 1)")
+		fmt.Println("  -timeout <d>     - Response timeout (default: 5s)")
+		fmt.Println("  -char-timeout <d> - Inter-character timeout (default: t1.5 plus slack)")
+		fmt.Println("  -echo            - The transceiver echoes what is sent (auto-direction chips, /RE")
+		fmt.Println("                     kept enabled): read back each request and detect collisions")
+		fmt.Println("  -turnaround <d>  - Quiet time after a broadcast write (default: 100ms)")
+		fmt.Println("  -de <pin>        - DE pin number (default: 17), \"rts\" to use the UART RTS line,")
+		fmt.Println("                     \"none\" for auto-direction transceivers; prefix ! for active-low")
+		fmt.Println("  -re <pin>        - RE pin number (default: 27), \"tied\" when DE and /RE share one pin")
+		fmt.Println("  -dir-setup <d>   - Delay after enabling the driver before sending, e.g. 50us")
+		fmt.Println("  -dir-hold <d>    - Delay after sending before releasing the driver")
+		fmt.Println("  -gpiochip <dev>  - Drive DE/RE through a GPIO character device, e.g. /dev/gpiochip0;")
+		fmt.Println("                     -de/-re then take line offsets or names such as GPIO17")
+		fmt.Println("  -slave <id>      - Slave ID (default: 1); 0 broadcasts a write to all slaves")
+		fmt.Println("  -addr <addr>     - Starting address (default: 0)")
+		fmt.Println("  -count <count>   - Count (default: 1)")
+		fmt.Println("  -value <value>   - Value to write (default: 0)")
+		fmt.Println("  -values <list>   - Comma-separated register values to write, e.g. 1,0x20,300")
+		fmt.Println("  -write-addr <addr> - Starting address of the write for readwrite (default: 0)")
+		fmt.Println("  -level <level>   - identify objects: basic, regular, extended or individual (default: basic)")
+		fmt.Println("  -object <id>     - First object for identify, or the only one at level individual (default: 0)")
+		fmt.Println("  -and <mask>      - AND mask for mask_write (default: 0xFFFF)")
+		fmt.Println("  -or <mask>       - OR mask for mask_write (default: 0)")
+		fmt.Println("  -bit <n>         - Register bit, 0 being the least significant (default: 0)")
+		fmt.Println("  -mask-fallback   - Read and write the whole register for slaves without function 0x16")
+		fmt.Println("  -file <n>        - File number for file-get and file-put (default: 1)")
+		fmt.Println("  -record <n>      - First record for file-get and file-put (default: 0)")
+		fmt.Println("  -path <file>     - Local file for file-get and file-put, registers stored big-endian")
+		fmt.Println("  -chunk <n>       - Registers per transaction for file-get and file-put (default: as many as fit)")
+		fmt.Println("  -retries <n>     - Retries after a timeout or CRC error (default: 0)")
+		fmt.Println("  -retry-backoff <d> - Delay before the first retry, doubled for each further one")
+		fmt.Println("  -retry-writes    - Retry writes too; only safe for idempotent writes")
+		fmt.Println("\nKernel RS-485 mode (replaces -de/-re):")
+		fmt.Println("  -rs485                   - Let the UART driver switch direction via RTS")
+		fmt.Println("  -rs485-rts-low           - Drive RTS low while sending")
+		fmt.Println("  -rs485-delay-before <d>  - Delay before send, e.g. 1ms")
+		fmt.Println("  -rs485-delay-after <d>   - Delay after send, e.g. 1ms")
+		fmt.Println("  -rs485-rx-during-tx      - Keep the receiver enabled while sending")
+		fmt.Println("\nExit status:")
+		fmt.Println("  0 success, 1 bad arguments or setup failure, 2 exception response,")
+		fmt.Println("  3 timeout, 4 CRC error, 5 invalid response, 6 serial port error,")
+		fmt.Println("  7 invalid request, 130 interrupted")
+	}
+}
@@ -1,4 +1,4 @@
-module modbus
+module github.com/btolarz/max485-raspberry-nodejs/go
 
 go 1.23.0
 
@@ -6,7 +6,5 @@ toolchain go1.23.7
 
 require (
 	github.com/stianeikeland/go-rpio/v4 v4.6.0
-	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
+	golang.org/x/sys v0.31.0
 )
-
-require golang.org/x/sys v0.31.0 // indirect
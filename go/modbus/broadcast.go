@@ -0,0 +1,17 @@
+package modbus
+
+// BroadcastID is the unit ID that addresses every slave on the bus at once.
+// Slaves carry out broadcast writes without answering them, so a broadcast
+// returns as soon as the request is sent, and the bus then stays quiet for
+// the turnaround delay of Timing to let them finish.
+const BroadcastID = 0
+
+// canBroadcast reports whether a request with function code fc may be sent
+// to BroadcastID. Reads cannot, as nobody would answer them.
+func canBroadcast(fc byte) bool {
+	switch fc {
+	case 0x05, 0x06, 0x0F, 0x10, 0x15, 0x16:
+		return true
+	}
+	return false
+}
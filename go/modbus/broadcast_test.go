@@ -0,0 +1,84 @@
+package modbus
+
+import (
+	"testing"
+	"time"
+)
+
+// unicastOnly builds a slave that answers like slave but, as real ones do,
+// stays silent on broadcasts
+func unicastOnly(slave func([]byte) []byte) func([]byte) []byte {
+	return func(request []byte) []byte {
+		if request[0] == BroadcastID {
+			return nil
+		}
+		return slave(request)
+	}
+}
+
+func TestBroadcastWrite(t *testing.T) {
+	transport := newFakeTransport(unicastOnly(holdingRegisters(nil)))
+	d := newTestDevice(t, transport, WithSerialOptions(SerialOptions{ResponseTimeout: time.Second}))
+	defer d.Close()
+
+	start := time.Now()
+	if err := d.WriteRegister(BroadcastID, 0, 1); err != nil {
+		t.Fatalf("broadcast WriteRegister: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("broadcast returned after %v, want without waiting for a response", elapsed)
+	}
+	if sent := transport.sent(); len(sent) != 1 || sent[0][0] != BroadcastID {
+		t.Errorf("sent % X, want one broadcast", sent)
+	}
+}
+
+func TestBroadcastTurnaround(t *testing.T) {
+	const turnaround = 200 * time.Millisecond
+	var sentAt []time.Time
+	slave := unicastOnly(holdingRegisters([]uint16{42}))
+	transport := newFakeTransport(func(request []byte) []byte {
+		sentAt = append(sentAt, time.Now())
+		return slave(request)
+	})
+	d := newTestDevice(t, transport, WithTiming(Timing{Turnaround: turnaround}))
+	defer d.Close()
+
+	if err := d.WriteRegister(BroadcastID, 0, 1); err != nil {
+		t.Fatalf("broadcast WriteRegister: %v", err)
+	}
+	if values, err := d.ReadHoldingRegisters(1, 0, 1); err != nil || values[0] != 42 {
+		t.Fatalf("ReadHoldingRegisters = %v, %v; want [42]", values, err)
+	}
+	if gap := sentAt[1].Sub(sentAt[0]); gap < turnaround {
+		t.Errorf("next request sent %v after the broadcast, want at least %v", gap, turnaround)
+	}
+
+	// Without a broadcast before it, a request waits t3.5 only
+	start := time.Now()
+	if _, err := d.ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Fatalf("ReadHoldingRegisters: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= turnaround {
+		t.Errorf("request after a unicast took %v, want less than the turnaround", elapsed)
+	}
+}
+
+func TestBroadcastRead(t *testing.T) {
+	transport := newFakeTransport(nil)
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	if _, err := d.ReadHoldingRegisters(BroadcastID, 0, 1); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("broadcast ReadHoldingRegisters error = %v, want ModbusInvalidRequest", err)
+	}
+	if _, err := d.ReadCoils(BroadcastID, 0, 1); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("broadcast ReadCoils error = %v, want ModbusInvalidRequest", err)
+	}
+	if _, err := d.ReadWriteMultipleRegisters(BroadcastID, 0, 1, 0, []uint16{1}); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("broadcast ReadWriteMultipleRegisters error = %v, want ModbusInvalidRequest", err)
+	}
+	if n := len(transport.sent()); n != 0 {
+		t.Errorf("sent %d broadcast reads", n)
+	}
+}
@@ -0,0 +1,862 @@
+// Package modbus is a Modbus RTU client for half-duplex RS-485 buses. It
+// drives the transceiver direction through GPIOs, the UART's RTS line or the
+// kernel's RS-485 mode, and keeps the RTU frame timing.
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timing configuration
+const (
+	// GPIO pin switching delays
+	gpioSwitchDelay = 1 * time.Microsecond
+
+	// DefaultResponseTimeout is how long to wait for the first byte of a
+	// response unless SerialOptions say otherwise
+	DefaultResponseTimeout = 5 * time.Second
+)
+
+// deviceConfig collects the settings applied by DeviceOptions
+type deviceConfig struct {
+	transport    Transport
+	direction    DirectionController
+	hasDirection bool
+	timing       Timing
+	rs485        RS485Config
+	serial       SerialOptions
+	statsHook    func(TransactionStats)
+	retry        RetryPolicy
+	reconnect    ReconnectConfig
+	stateHook    func(ConnectionState)
+
+	maskWriteFallback bool
+
+	directionConfig DirectionConfig
+
+	// GPIO character device used instead of go-rpio
+	gpioChip string
+	deLine   string
+	reLine   string
+}
+
+// DeviceOption customizes a ModbusDevice created by NewModbusDevice
+type DeviceOption func(*deviceConfig)
+
+// WithTransport makes the device use t instead of opening portName
+func WithTransport(t Transport) DeviceOption {
+	return func(c *deviceConfig) {
+		c.transport = t
+	}
+}
+
+// WithDirection makes the device use dc instead of the dePin/rePin GPIOs.
+// A nil controller disables direction switching altogether.
+func WithDirection(dc DirectionController) DeviceOption {
+	return func(c *deviceConfig) {
+		c.direction = dc
+		c.hasDirection = true
+	}
+}
+
+// WithGPIOChip drives DE and RE through a GPIO character device such as
+// /dev/gpiochip0 instead of go-rpio, which works on any SoC with a GPIO
+// driver. deLine and reLine are line offsets ("17") or line names
+// ("GPIO17"); empty ones default to dePin and rePin.
+func WithGPIOChip(chipPath, deLine, reLine string) DeviceOption {
+	return func(c *deviceConfig) {
+		c.gpioChip = chipPath
+		c.deLine = deLine
+		c.reLine = reLine
+	}
+}
+
+// WithTiming overrides the intervals derived from the baud rate. Zero
+// fields keep their derived values.
+func WithTiming(t Timing) DeviceOption {
+	return func(c *deviceConfig) {
+		c.timing = t
+	}
+}
+
+// openSerialTransport opens portName as a serial Transport
+func openSerialTransport(portName string, baudRate int, opts SerialOptions) (Transport, error) {
+	port, err := openSerialPort(portName, baudRate, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serial port: %v", err)
+	}
+	return port, nil
+}
+
+// NewModbusDevice creates a new Modbus device. By default it opens portName
+// and switches the transceiver through the dePin/rePin GPIOs; either half
+// can be replaced with WithTransport and WithDirection.
+func NewModbusDevice(portName string, baudRate int, dePin, rePin int, opts ...DeviceOption) (*ModbusDevice, error) {
+	var cfg deviceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if baudRate <= 0 {
+		return nil, fmt.Errorf("invalid baud rate %d", baudRate)
+	}
+	serialOpts, err := cfg.serial.withDefaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid serial options: %v", err)
+	}
+	retry, err := cfg.retry.withDefaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry policy: %v", err)
+	}
+
+	if cfg.reconnect.Interval < 0 {
+		return nil, fmt.Errorf("invalid reconnect interval %v", cfg.reconnect.Interval)
+	}
+	if cfg.reconnect.Interval == 0 {
+		cfg.reconnect.Interval = defaultReconnectInterval
+	}
+
+	conn := &connector{
+		portName:  portName,
+		baudRate:  baudRate,
+		serial:    serialOpts,
+		rs485:     cfg.rs485,
+		reconnect: cfg.reconnect,
+	}
+	if cfg.reconnect.ByID {
+		conn.portName = serialByIDPath(portName)
+	}
+
+	transport := cfg.transport
+	if transport == nil {
+		var err error
+		transport, err = conn.open()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Only ports opened here can be reopened
+		conn = nil
+		if err := setupTransport(transport, cfg.rs485); err != nil {
+			return nil, err
+		}
+	}
+
+	// In kernel RS-485 mode the UART driver switches direction itself
+	if cfg.rs485.Enabled && !cfg.hasDirection {
+		cfg.direction = nil
+		cfg.hasDirection = true
+	}
+
+	direction := cfg.direction
+	if !cfg.hasDirection {
+		var err error
+		direction, err = newDirection(transport, dePin, rePin, &cfg)
+		if err != nil {
+			transport.Close()
+			return nil, err
+		}
+		// RTS belongs to the port and has to be taken over from a new one
+		if conn != nil && cfg.directionConfig.Mode == DirectionRTS {
+			conn.direction = func(t Transport) (DirectionController, error) {
+				return newDirection(t, dePin, rePin, &cfg)
+			}
+		}
+	} else if direction == nil {
+		direction = noDirection{}
+	}
+
+	// Set initial state to receive mode
+	if err := direction.EnableRX(); err != nil {
+		transport.Close()
+		direction.Close()
+		return nil, fmt.Errorf("failed to enable receive mode: %v", err)
+	}
+
+	timing := NewTiming(baudRate, serialOpts.bitsPerChar()).withOverrides(cfg.timing)
+	interCharTimeout := serialOpts.InterCharTimeout
+	if interCharTimeout == 0 {
+		interCharTimeout = timing.interCharTimeout()
+	}
+
+	return &ModbusDevice{
+		transport:         transport,
+		direction:         direction,
+		timing:            timing,
+		responseTimeout:   serialOpts.ResponseTimeout,
+		interCharTimeout:  interCharTimeout,
+		localEcho:         serialOpts.LocalEcho || cfg.rs485.Enabled && cfg.rs485.RXDuringTX,
+		statsHook:         cfg.statsHook,
+		stateHook:         cfg.stateHook,
+		retry:             retry,
+		maskWriteFallback: cfg.maskWriteFallback,
+		conn:              conn,
+	}, nil
+}
+
+// setupTransport flushes stale input from a newly opened transport and
+// enables kernel RS-485 mode if requested. It closes the transport when it
+// fails.
+func setupTransport(transport Transport, rs485 RS485Config) error {
+	if err := transport.Flush(); err != nil {
+		transport.Close()
+		return fmt.Errorf("failed to flush port: %v", err)
+	}
+
+	if rs485.Enabled {
+		setter, ok := transport.(rs485Setter)
+		if !ok {
+			transport.Close()
+			return fmt.Errorf("transport does not support kernel RS-485 mode")
+		}
+		if err := setter.SetRS485(rs485); err != nil {
+			transport.Close()
+			return fmt.Errorf("failed to enable kernel RS-485 mode: %v", err)
+		}
+	}
+	return nil
+}
+
+// newDirection builds the direction controller selected by cfg
+func newDirection(transport Transport, dePin, rePin int, cfg *deviceConfig) (DirectionController, error) {
+	dc := cfg.directionConfig
+	switch dc.Mode {
+	case DirectionNone:
+		return noDirection{}, nil
+	case DirectionRTS:
+		rts, err := NewRTSDirection(transport, dc.InvertDE)
+		if err != nil {
+			return nil, err
+		}
+		return withSetupHold(rts, dc), nil
+	}
+
+	if cfg.gpioChip != "" {
+		return NewGPIOChipDirection(cfg.gpioChip,
+			lineOrPin(cfg.deLine, dePin), lineOrPin(cfg.reLine, rePin), dc)
+	}
+	return NewGPIODirection(dePin, rePin, dc)
+}
+
+// lineOrPin returns line, or pin as a line offset when line is empty
+func lineOrPin(line string, pin int) string {
+	if line == "" {
+		return strconv.Itoa(pin)
+	}
+	return line
+}
+
+// Close closes the Modbus device. It queues for the bus like a transaction,
+// so the transactions queued before it are carried out first; those queued
+// after it fail with ErrClosed. Closing it again does nothing.
+func (d *ModbusDevice) Close() {
+	d.queue.acquire(context.Background())
+	defer d.queue.release()
+
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.setState(StateClosed)
+	if d.transport != nil {
+		// Let the slaves finish a broadcast before the port goes
+		d.waitForSilence()
+		d.transport.Close()
+	}
+	if d.direction != nil {
+		d.direction.Close()
+	}
+}
+
+// calculateCRC calculates CRC-16 for Modbus RTU
+func calculateCRC(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 == 1 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// appendCRC returns frame with its CRC appended
+func appendCRC(frame []byte) []byte {
+	crc := calculateCRC(frame)
+	return append(frame[:len(frame):len(frame)], byte(crc&0xFF), byte(crc>>8))
+}
+
+// checkEcho verifies that the response to a write repeats the first n bytes
+// of request: slave ID, function code, address and value or quantity.
+// Broadcasts have no response to check.
+func checkEcho(request, response []byte, n int) error {
+	if response == nil || bytes.Equal(response[:n], request[:n]) {
+		return nil
+	}
+	return newError(ModbusInvalidResponse, request, response, &MismatchError{
+		Request:  appendCRC(request),
+		Response: response,
+	})
+}
+
+// enableTX enables RS485 transmit mode
+func (d *ModbusDevice) enableTX() error {
+	if err := d.direction.EnableTX(); err != nil {
+		return fmt.Errorf("failed to enable transmit mode: %w", err)
+	}
+	return nil
+}
+
+// enableRX enables RS485 receive mode
+func (d *ModbusDevice) enableRX() error {
+	if err := d.direction.EnableRX(); err != nil {
+		return fmt.Errorf("failed to enable receive mode: %w", err)
+	}
+	return nil
+}
+
+// sendModbusRequest sends a Modbus request and waits for response. It is
+// safe for concurrent use: transactions queue for the bus in FIFO order.
+//
+// ctx bounds both the wait in the queue and the wait for the response.
+// Failed attempts are repeated as the retry policy allows, each one queuing
+// for the bus again after the backoff. Requests to reserved slave IDs and
+// reads from BroadcastID fail without touching the bus.
+func (d *ModbusDevice) sendModbusRequest(ctx context.Context, request []byte) ([]byte, error) {
+	if request[0] > MaxSlaveID {
+		return nil, newError(ModbusInvalidRequest, request, nil,
+			fmt.Errorf("invalid slave ID %d: must be 1 to %d, or %d to broadcast", request[0], MaxSlaveID, BroadcastID))
+	}
+	if request[0] == BroadcastID && !canBroadcast(request[1]) {
+		return nil, newError(ModbusInvalidRequest, request, nil,
+			fmt.Errorf("function 0x%02X cannot be broadcast", request[1]))
+	}
+
+	opts := callOptions(ctx)
+	policy := d.retry
+	if opts.Retry != nil {
+		var err error
+		if policy, err = opts.Retry.withDefaults(); err != nil {
+			return nil, newError(ModbusInvalidRequest, request, nil, fmt.Errorf("invalid retry policy: %v", err))
+		}
+	}
+	if isWriteFunction(request[1]) && !policy.RetryWrites && !opts.Idempotent {
+		policy.MaxAttempts = 1
+	}
+
+	var response []byte
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		response, err = d.attempt(ctx, request, attempt)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) || ctx.Err() != nil {
+			break
+		}
+		if sleepContext(ctx, policy.backoff(attempt)) != nil {
+			break
+		}
+	}
+	if opts.Attempts != nil {
+		*opts.Attempts = attempt
+	}
+	return response, err
+}
+
+// attempt makes one transaction on the bus
+func (d *ModbusDevice) attempt(ctx context.Context, request []byte, attempt int) ([]byte, error) {
+	queued := time.Now()
+	if err := d.queue.acquire(ctx); err != nil {
+		return nil, contextError(request, err)
+	}
+	started := time.Now()
+	response, err := d.transact(ctx, request)
+	if isDisconnect(err) {
+		d.lost()
+	} else if d.transport != nil && !d.closed {
+		d.setState(StateConnected)
+	}
+	finished := time.Now()
+	d.queue.release()
+
+	if d.statsHook != nil {
+		d.statsHook(TransactionStats{
+			SlaveID:      request[0],
+			FunctionCode: request[1],
+			Attempt:      attempt,
+			QueueWait:    started.Sub(queued),
+			BusTime:      finished.Sub(started),
+			Err:          err,
+		})
+	}
+	return response, err
+}
+
+// transact performs one exchange on the bus, which the caller must own.
+// The response length is taken from the response itself, see readResponse.
+// Broadcasts and Force Listen Only Mode have no response and return a nil
+// one, as does Restart Communications when it goes unanswered.
+func (d *ModbusDevice) transact(ctx context.Context, request []byte) ([]byte, error) {
+	if d.closed {
+		return nil, newError(ModbusSerialError, request, nil, ErrClosed)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(request, err)
+	}
+	// The reconnect loop reopens a lost port; calls meanwhile fail at once
+	if d.transport == nil {
+		return nil, newError(ModbusSerialError, request, nil, ErrDisconnected)
+	}
+
+	request = appendCRC(request)
+
+	// A response to an aborted request may have arrived since
+	if d.staleInput {
+		if err := d.transport.Flush(); err != nil {
+			return nil, newError(ModbusSerialError, request, nil, fmt.Errorf("failed to flush port: %w", err))
+		}
+		d.staleInput = false
+	}
+
+	// Keep the inter-frame silence before taking the bus
+	d.waitForSilence()
+
+	// Send request
+	if err := d.enableTX(); err != nil {
+		return nil, newError(ModbusSerialError, request, nil, err)
+	}
+
+	// Send the whole frame in one write so no gap opens up between
+	// characters, then hold TX until the last stop bit has left the UART
+	n, err := d.transport.Write(request)
+	if err != nil {
+		d.enableRX()
+		return nil, newError(ModbusSerialError, request, nil, fmt.Errorf("failed to write request: %w", err))
+	}
+	if n != len(request) {
+		d.enableRX()
+		return nil, newError(ModbusSerialError, request, nil, fmt.Errorf("failed to write request: wrote %d of %d bytes", n, len(request)))
+	}
+	if err := d.drain(len(request)); err != nil {
+		d.enableRX()
+		return nil, newError(ModbusSerialError, request, nil, err)
+	}
+	d.lastActivity = time.Now()
+
+	// Wait for response
+	if err := d.enableRX(); err != nil {
+		return nil, newError(ModbusSerialError, request, nil, err)
+	}
+	if d.localEcho {
+		if err := d.readEcho(ctx, request); err != nil {
+			return nil, err
+		}
+	}
+
+	// Nobody answers a broadcast, but the slaves need time to carry it out
+	if request[0] == BroadcastID {
+		d.quietUntil = d.lastActivity.Add(d.timing.Turnaround)
+		return nil, nil
+	}
+	// Nor does a slave told to listen only
+	if isListenOnly(request) {
+		return nil, nil
+	}
+
+	response, err := d.readResponse(ctx, request)
+	if err != nil {
+		// Silence is the answer to Restart Communications from a slave in
+		// listen only mode
+		if isRestart(request) && ErrorCode(err) == ModbusTimeoutError {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// Verify slave ID. A frame failing this or the CRC check is noise or a
+	// late reply, and whatever follows it must not be taken for the next
+	// response.
+	if response[0] != request[0] {
+		d.staleInput = true
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid slave ID in response: got %d, expected %d", response[0], request[0]))
+	}
+
+	// Verify CRC
+	receivedCRC := binary.LittleEndian.Uint16(response[len(response)-2:])
+	calculatedCRC := calculateCRC(response[:len(response)-2])
+	if receivedCRC != calculatedCRC {
+		d.staleInput = true
+		return nil, newError(ModbusCRCError, request, response,
+			fmt.Errorf("%w: received %04X, calculated %04X", ErrCRC, receivedCRC, calculatedCRC))
+	}
+
+	// Decode exception response
+	if response[1] == request[1]|exceptionFlag {
+		return nil, &ExceptionError{
+			SlaveID:      response[0],
+			FunctionCode: request[1],
+			Code:         ExceptionCode(response[2]),
+			Frame:        response,
+		}
+	}
+
+	// Verify function code
+	if response[1] != request[1] {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid function code in response: got %d, expected %d", response[1], request[1]))
+	}
+
+	return response, nil
+}
+
+// ReadCoils reads coils from a Modbus slave
+func (d *ModbusDevice) ReadCoils(slaveID byte, startAddr uint16, count uint16) ([]bool, error) {
+	return d.ReadCoilsContext(context.Background(), slaveID, startAddr, count)
+}
+
+// ReadCoilsContext is ReadCoils with a context bounding the wait for the bus
+// and for the response
+func (d *ModbusDevice) ReadCoilsContext(ctx context.Context, slaveID byte, startAddr uint16, count uint16) ([]bool, error) {
+	if err := checkRange(slaveID, 0x01, startAddr, int(count), MaxReadBits); err != nil {
+		return nil, err
+	}
+
+	request := []byte{
+		slaveID,
+		0x01,
+		byte(startAddr >> 8),
+		byte(startAddr & 0xFF),
+		byte(count >> 8),
+		byte(count & 0xFF),
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	byteCount := response[2]
+	if int(byteCount) != int(count+7)/8 {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected %d", byteCount, (count+7)/8))
+	}
+	result := make([]bool, count)
+	for i := uint16(0); i < count; i++ {
+		byteIndex := i / 8
+		bitIndex := i % 8
+		if byteIndex < uint16(byteCount) {
+			result[i] = (response[3+byteIndex] & (1 << bitIndex)) != 0
+		}
+	}
+
+	return result, nil
+}
+
+// ReadDiscreteInputs reads discrete inputs from a Modbus slave
+func (d *ModbusDevice) ReadDiscreteInputs(slaveID byte, startAddr uint16, count uint16) ([]bool, error) {
+	return d.ReadDiscreteInputsContext(context.Background(), slaveID, startAddr, count)
+}
+
+// ReadDiscreteInputsContext is ReadDiscreteInputs with a context bounding the
+// wait for the bus and for the response
+func (d *ModbusDevice) ReadDiscreteInputsContext(ctx context.Context, slaveID byte, startAddr uint16, count uint16) ([]bool, error) {
+	if err := checkRange(slaveID, 0x02, startAddr, int(count), MaxReadBits); err != nil {
+		return nil, err
+	}
+
+	request := []byte{
+		slaveID,
+		0x02,
+		byte(startAddr >> 8),
+		byte(startAddr & 0xFF),
+		byte(count >> 8),
+		byte(count & 0xFF),
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	byteCount := response[2]
+	if int(byteCount) != int(count+7)/8 {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected %d", byteCount, (count+7)/8))
+	}
+	result := make([]bool, count)
+	for i := uint16(0); i < count; i++ {
+		byteIndex := i / 8
+		bitIndex := i % 8
+		if byteIndex < uint16(byteCount) {
+			result[i] = (response[3+byteIndex] & (1 << bitIndex)) != 0
+		}
+	}
+
+	return result, nil
+}
+
+// ReadHoldingRegisters reads holding registers from a Modbus slave
+func (d *ModbusDevice) ReadHoldingRegisters(slaveID byte, startAddr uint16, count uint16) ([]uint16, error) {
+	return d.ReadHoldingRegistersContext(context.Background(), slaveID, startAddr, count)
+}
+
+// ReadHoldingRegistersContext is ReadHoldingRegisters with a context bounding
+// the wait for the bus and for the response
+func (d *ModbusDevice) ReadHoldingRegistersContext(ctx context.Context, slaveID byte, startAddr uint16, count uint16) ([]uint16, error) {
+	if err := checkRange(slaveID, 0x03, startAddr, int(count), MaxReadRegisters); err != nil {
+		return nil, err
+	}
+
+	request := []byte{
+		slaveID,
+		0x03,
+		byte(startAddr >> 8),
+		byte(startAddr & 0xFF),
+		byte(count >> 8),
+		byte(count & 0xFF),
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	byteCount := response[2]
+	if int(byteCount) != 2*int(count) {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected %d", byteCount, 2*int(count)))
+	}
+	result := make([]uint16, count)
+	for i := uint16(0); i < count; i++ {
+		if 2*i+1 < uint16(byteCount) {
+			result[i] = uint16(response[3+2*i])<<8 | uint16(response[4+2*i])
+		}
+	}
+
+	return result, nil
+}
+
+// ReadInputRegisters reads input registers from a Modbus slave
+func (d *ModbusDevice) ReadInputRegisters(slaveID byte, startAddr uint16, count uint16) ([]uint16, error) {
+	return d.ReadInputRegistersContext(context.Background(), slaveID, startAddr, count)
+}
+
+// ReadInputRegistersContext is ReadInputRegisters with a context bounding the
+// wait for the bus and for the response
+func (d *ModbusDevice) ReadInputRegistersContext(ctx context.Context, slaveID byte, startAddr uint16, count uint16) ([]uint16, error) {
+	if err := checkRange(slaveID, 0x04, startAddr, int(count), MaxReadRegisters); err != nil {
+		return nil, err
+	}
+
+	request := []byte{
+		slaveID,
+		0x04,
+		byte(startAddr >> 8),
+		byte(startAddr & 0xFF),
+		byte(count >> 8),
+		byte(count & 0xFF),
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	byteCount := response[2]
+	if int(byteCount) != 2*int(count) {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected %d", byteCount, 2*int(count)))
+	}
+	result := make([]uint16, count)
+	for i := uint16(0); i < count; i++ {
+		if 2*i+1 < uint16(byteCount) {
+			result[i] = uint16(response[3+2*i])<<8 | uint16(response[4+2*i])
+		}
+	}
+
+	return result, nil
+}
+
+// WriteCoil writes a single coil to a Modbus slave, or to all of them
+// at once with BroadcastID
+func (d *ModbusDevice) WriteCoil(slaveID byte, coilAddr uint16, value bool) error {
+	return d.WriteCoilContext(context.Background(), slaveID, coilAddr, value)
+}
+
+// WriteCoilContext is WriteCoil with a context bounding the wait for the bus
+// and for the response
+func (d *ModbusDevice) WriteCoilContext(ctx context.Context, slaveID byte, coilAddr uint16, value bool) error {
+	request := []byte{
+		slaveID,
+		0x05,
+		byte(coilAddr >> 8),
+		byte(coilAddr & 0xFF),
+		0x00,
+		0x00,
+	}
+	if value {
+		request[4] = 0xFF
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+	return checkEcho(request, response, 6)
+}
+
+// WriteRegister writes a single holding register to a Modbus slave, or to
+// all of them at once with BroadcastID
+func (d *ModbusDevice) WriteRegister(slaveID byte, regAddr uint16, value uint16) error {
+	return d.WriteRegisterContext(context.Background(), slaveID, regAddr, value)
+}
+
+// WriteRegisterContext is WriteRegister with a context bounding the wait for
+// the bus and for the response
+func (d *ModbusDevice) WriteRegisterContext(ctx context.Context, slaveID byte, regAddr uint16, value uint16) error {
+	request := []byte{
+		slaveID,
+		0x06,
+		byte(regAddr >> 8),
+		byte(regAddr & 0xFF),
+		byte(value >> 8),
+		byte(value & 0xFF),
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+	return checkEcho(request, response, 6)
+}
+
+// WriteMultipleCoils writes multiple coils to a Modbus slave, or to all of
+// them at once with BroadcastID
+func (d *ModbusDevice) WriteMultipleCoils(slaveID byte, startAddr uint16, values []bool) error {
+	return d.WriteMultipleCoilsContext(context.Background(), slaveID, startAddr, values)
+}
+
+// WriteMultipleCoilsContext is WriteMultipleCoils with a context bounding the
+// wait for the bus and for the response
+func (d *ModbusDevice) WriteMultipleCoilsContext(ctx context.Context, slaveID byte, startAddr uint16, values []bool) error {
+	if err := checkRange(slaveID, 0x0F, startAddr, len(values), MaxWriteCoils); err != nil {
+		return err
+	}
+
+	byteCount := (len(values) + 7) / 8
+	request := make([]byte, 7+byteCount)
+	request[0] = slaveID
+	request[1] = 0x0F
+	request[2] = byte(startAddr >> 8)
+	request[3] = byte(startAddr & 0xFF)
+	request[4] = byte(len(values) >> 8)
+	request[5] = byte(len(values) & 0xFF)
+	request[6] = byte(byteCount)
+
+	for i, value := range values {
+		if value {
+			request[7+i/8] |= 1 << (i % 8)
+		}
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+	return checkEcho(request, response, 6)
+}
+
+// WriteMultipleRegisters writes multiple holding registers to a Modbus
+// slave, or to all of them at once with BroadcastID
+func (d *ModbusDevice) WriteMultipleRegisters(slaveID byte, startAddr uint16, values []uint16) error {
+	return d.WriteMultipleRegistersContext(context.Background(), slaveID, startAddr, values)
+}
+
+// WriteMultipleRegistersContext is WriteMultipleRegisters with a context
+// bounding the wait for the bus and for the response
+func (d *ModbusDevice) WriteMultipleRegistersContext(ctx context.Context, slaveID byte, startAddr uint16, values []uint16) error {
+	if err := checkRange(slaveID, 0x10, startAddr, len(values), MaxWriteRegisters); err != nil {
+		return err
+	}
+
+	request := make([]byte, 7+2*len(values))
+	request[0] = slaveID
+	request[1] = 0x10
+	request[2] = byte(startAddr >> 8)
+	request[3] = byte(startAddr & 0xFF)
+	request[4] = byte(len(values) >> 8)
+	request[5] = byte(len(values) & 0xFF)
+	request[6] = byte(2 * len(values))
+
+	for i, value := range values {
+		request[7+2*i] = byte(value >> 8)
+		request[8+2*i] = byte(value & 0xFF)
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+	return checkEcho(request, response, 6)
+}
+
+// ReadWriteMultipleRegisters writes values to the holding registers from
+// writeAddr and reads count holding registers from readAddr in one
+// transaction (function 0x17). The slave does the write first.
+func (d *ModbusDevice) ReadWriteMultipleRegisters(slaveID byte, readAddr uint16, count uint16, writeAddr uint16, values []uint16) ([]uint16, error) {
+	return d.ReadWriteMultipleRegistersContext(context.Background(), slaveID, readAddr, count, writeAddr, values)
+}
+
+// ReadWriteMultipleRegistersContext is ReadWriteMultipleRegisters with a
+// context bounding the wait for the bus and for the response
+func (d *ModbusDevice) ReadWriteMultipleRegistersContext(ctx context.Context, slaveID byte, readAddr uint16, count uint16, writeAddr uint16, values []uint16) ([]uint16, error) {
+	if err := checkRange(slaveID, 0x17, readAddr, int(count), MaxReadRegisters); err != nil {
+		return nil, err
+	}
+	if err := checkRange(slaveID, 0x17, writeAddr, len(values), MaxReadWriteRegisters); err != nil {
+		return nil, err
+	}
+
+	request := make([]byte, 11+2*len(values))
+	request[0] = slaveID
+	request[1] = 0x17
+	request[2] = byte(readAddr >> 8)
+	request[3] = byte(readAddr & 0xFF)
+	request[4] = byte(count >> 8)
+	request[5] = byte(count & 0xFF)
+	request[6] = byte(writeAddr >> 8)
+	request[7] = byte(writeAddr & 0xFF)
+	request[8] = byte(len(values) >> 8)
+	request[9] = byte(len(values) & 0xFF)
+	request[10] = byte(2 * len(values))
+
+	for i, value := range values {
+		request[11+2*i] = byte(value >> 8)
+		request[12+2*i] = byte(value & 0xFF)
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	byteCount := response[2]
+	if int(byteCount) != 2*int(count) {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected %d", byteCount, 2*int(count)))
+	}
+	result := make([]uint16, count)
+	for i := uint16(0); i < count; i++ {
+		result[i] = uint16(response[3+2*i])<<8 | uint16(response[4+2*i])
+	}
+
+	return result, nil
+}
@@ -0,0 +1,192 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAppendCRC(t *testing.T) {
+	frame := make([]byte, 6, 16)
+	copy(frame, []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A})
+
+	got := appendCRC(frame)
+	want := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A, 0xC5, 0xCD}
+	if !bytes.Equal(got, want) {
+		t.Errorf("appendCRC = % X, want % X", got, want)
+	}
+	if extra := frame[:8]; extra[6] != 0 || extra[7] != 0 {
+		t.Errorf("appendCRC wrote into the spare capacity of its argument: % X", extra)
+	}
+	if crc := calculateCRC(got); crc != 0 {
+		t.Errorf("CRC over a frame and its CRC = %04X, want 0", crc)
+	}
+}
+
+func TestCheckEcho(t *testing.T) {
+	request := []byte{1, 0x06, 0x00, 0x10, 0x12, 0x34}
+
+	if err := checkEcho(request, appendCRC(request), 6); err != nil {
+		t.Errorf("checkEcho of a matching response = %v", err)
+	}
+	if err := checkEcho(request, nil, 6); err != nil {
+		t.Errorf("checkEcho of a broadcast = %v", err)
+	}
+
+	response := appendCRC([]byte{1, 0x06, 0x00, 0x10, 0x12, 0x35})
+	err := checkEcho(request, response, 6)
+	var mismatch *MismatchError
+	if !errors.As(err, &mismatch) || ErrorCode(err) != ModbusInvalidResponse {
+		t.Fatalf("checkEcho of a differing response = %v, want a *MismatchError", err)
+	}
+	if !bytes.Equal(mismatch.Request, appendCRC(request)) || !bytes.Equal(mismatch.Response, response) {
+		t.Errorf("MismatchError = % X / % X", mismatch.Request, mismatch.Response)
+	}
+}
+
+func TestWriteRegisterMismatch(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(respond(1, 0x06, 0x00, 0x10, 0x00, 0x00)))
+	defer d.Close()
+
+	var mismatch *MismatchError
+	if err := d.WriteRegister(1, 0x10, 0x1234); !errors.As(err, &mismatch) {
+		t.Errorf("WriteRegister error = %v, want a *MismatchError", err)
+	}
+}
+
+func TestReservedSlaveID(t *testing.T) {
+	transport := newFakeTransport(nil)
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	if _, err := d.ReadHoldingRegisters(MaxSlaveID+1, 0, 1); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("ReadHoldingRegisters from slave %d error = %v, want ModbusInvalidRequest", MaxSlaveID+1, err)
+	}
+	if len(transport.sent()) != 0 {
+		t.Error("a request to a reserved slave ID reached the bus")
+	}
+}
+
+func TestClosedDevice(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(holdingRegisters([]uint16{1})))
+	d.Close()
+
+	if _, err := d.ReadHoldingRegisters(1, 0, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadHoldingRegisters after Close error = %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	transport := newFakeTransport(nil)
+	d := newTestDevice(t, transport)
+	d.Close()
+	d.Close()
+
+	if transport.closes != 1 {
+		t.Errorf("transport closed %d times, want 1", transport.closes)
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(nil))
+	defer d.Close()
+
+	writes := map[string]func() error{
+		"WriteCoil":              func() error { return d.WriteCoil(1, 0, true) },
+		"WriteRegister":          func() error { return d.WriteRegister(1, 0, 1) },
+		"WriteMultipleCoils":     func() error { return d.WriteMultipleCoils(1, 0, []bool{true}) },
+		"WriteMultipleRegisters": func() error { return d.WriteMultipleRegisters(1, 0, []uint16{1}) },
+		"MaskWriteRegister":      func() error { return d.MaskWriteRegister(1, 0, 0xFFFF, 0) },
+		"WriteFileRecords":       func() error { return d.WriteFileRecords(1, []FileRecord{{File: 1, Data: []uint16{1}}}) },
+	}
+	for name, write := range writes {
+		// Every write returns the *Error of the failed transaction as is
+		err := write()
+		if e, ok := err.(*Error); !ok || e.Code != ModbusTimeoutError {
+			t.Errorf("%s error = %#v, want a timeout *Error", name, err)
+		}
+	}
+}
+
+// readWriteSlave builds a slave that answers Read/Write Multiple Registers
+// with registers counting up from the read address
+func readWriteSlave(request []byte) []byte {
+	addr, count := int(request[2])<<8|int(request[3]), int(request[4])<<8|int(request[5])
+	body := []byte{request[0], 0x17, byte(2 * count)}
+	for i := range count {
+		body = append(body, byte((addr+i)>>8), byte(addr+i))
+	}
+	return appendCRC(body)
+}
+
+func TestReadWriteMultipleRegisters(t *testing.T) {
+	// The example of the specification
+	transport := newFakeTransport(respond(1, 0x17, 0x0C,
+		0x00, 0xFE, 0x0A, 0xCD, 0x00, 0x01, 0x00, 0x03, 0x00, 0x0D, 0x00, 0xFF))
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	values, err := d.ReadWriteMultipleRegisters(1, 0x0003, 6, 0x000E, []uint16{0x00FF, 0x00FF, 0x00FF})
+	if err != nil {
+		t.Fatalf("ReadWriteMultipleRegisters: %v", err)
+	}
+	if want := []uint16{0x00FE, 0x0ACD, 0x0001, 0x0003, 0x000D, 0x00FF}; !reflect.DeepEqual(values, want) {
+		t.Errorf("ReadWriteMultipleRegisters = %04X, want %04X", values, want)
+	}
+	want := appendCRC([]byte{1, 0x17, 0x00, 0x03, 0x00, 0x06, 0x00, 0x0E, 0x00, 0x03, 0x06, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF})
+	if sent := transport.sent(); len(sent) != 1 || !bytes.Equal(sent[0], want) {
+		t.Errorf("sent % X, want % X", sent, want)
+	}
+}
+
+func TestReadWriteMultipleRegistersByteCount(t *testing.T) {
+	// Two registers where six were asked for
+	d := newTestDevice(t, newFakeTransport(respond(1, 0x17, 0x04, 0x00, 0x01, 0x00, 0x02)))
+	defer d.Close()
+
+	if _, err := d.ReadWriteMultipleRegisters(1, 0, 6, 0, []uint16{1}); ErrorCode(err) != ModbusInvalidResponse {
+		t.Errorf("ReadWriteMultipleRegisters error = %v, want ModbusInvalidResponse", err)
+	}
+}
+
+func TestReadWriteMultipleRegistersLimits(t *testing.T) {
+	transport := newFakeTransport(readWriteSlave)
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	if values, err := d.ReadWriteMultipleRegisters(1, 0, MaxReadRegisters, 0, make([]uint16, MaxReadWriteRegisters)); err != nil || len(values) != MaxReadRegisters {
+		t.Errorf("ReadWriteMultipleRegisters at the limits = %d registers, %v", len(values), err)
+	}
+
+	invalid := map[string]func() error{
+		"reading too many": func() error {
+			_, err := d.ReadWriteMultipleRegisters(1, 0, MaxReadRegisters+1, 0, []uint16{1})
+			return err
+		},
+		"writing too many": func() error {
+			_, err := d.ReadWriteMultipleRegisters(1, 0, 1, 0, make([]uint16, MaxReadWriteRegisters+1))
+			return err
+		},
+		"reading none": func() error {
+			_, err := d.ReadWriteMultipleRegisters(1, 0, 0, 0, []uint16{1})
+			return err
+		},
+		"writing none": func() error {
+			_, err := d.ReadWriteMultipleRegisters(1, 0, 1, 0, nil)
+			return err
+		},
+		"writing past the last address": func() error {
+			_, err := d.ReadWriteMultipleRegisters(1, 0, 1, 0xFFFF, []uint16{1, 2})
+			return err
+		},
+	}
+	for name, call := range invalid {
+		if err := call(); ErrorCode(err) != ModbusInvalidRequest {
+			t.Errorf("%s: error = %v, want ModbusInvalidRequest", name, err)
+		}
+	}
+	if n := len(transport.sent()); n != 1 {
+		t.Errorf("sent %d requests, want only the one within the limits", n)
+	}
+}
@@ -0,0 +1,102 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeviceIDCode selects which objects ReadDeviceIdentification reads
+type DeviceIDCode byte
+
+const (
+	// DeviceIDBasic streams the mandatory objects 0x00 to 0x02
+	DeviceIDBasic DeviceIDCode = 0x01
+	// DeviceIDRegular streams the optional objects 0x03 to 0x7F as well
+	DeviceIDRegular DeviceIDCode = 0x02
+	// DeviceIDExtended streams the private objects 0x80 to 0xFF as well
+	DeviceIDExtended DeviceIDCode = 0x03
+	// DeviceIDIndividual reads the one object asked for
+	DeviceIDIndividual DeviceIDCode = 0x04
+)
+
+// Object IDs the specification defines
+const (
+	ObjectVendorName          byte = 0x00
+	ObjectProductCode         byte = 0x01
+	ObjectMajorMinorRevision  byte = 0x02
+	ObjectVendorURL           byte = 0x03
+	ObjectProductName         byte = 0x04
+	ObjectModelName           byte = 0x05
+	ObjectUserApplicationName byte = 0x06
+)
+
+// meiReadDeviceID is the MEI type of Read Device Identification
+const meiReadDeviceID = 0x0E
+
+// moreFollows marks a response after which the slave has more objects
+const moreFollows = 0xFF
+
+// ReadDeviceIdentification reads identification objects such as the vendor
+// name, product code and revision (function 0x2B, MEI type 0x0E) and returns
+// them by object ID. Stream access codes read from objectID (usually 0) to
+// the end of their category, taking as many transactions as the slave needs
+// to send them all; DeviceIDIndividual reads objectID only.
+func (d *ModbusDevice) ReadDeviceIdentification(slaveID byte, code DeviceIDCode, objectID byte) (map[byte]string, error) {
+	return d.ReadDeviceIdentificationContext(context.Background(), slaveID, code, objectID)
+}
+
+// ReadDeviceIdentificationContext is ReadDeviceIdentification with a context
+// bounding the wait for the bus and for the responses
+func (d *ModbusDevice) ReadDeviceIdentificationContext(ctx context.Context, slaveID byte, code DeviceIDCode, objectID byte) (map[byte]string, error) {
+	if code < DeviceIDBasic || code > DeviceIDIndividual {
+		return nil, newError(ModbusInvalidRequest, []byte{slaveID, 0x2B}, nil,
+			fmt.Errorf("invalid read device ID code %d: must be 1 to 4", code))
+	}
+
+	objects := make(map[byte]string)
+	for {
+		request := []byte{slaveID, 0x2B, meiReadDeviceID, byte(code), objectID}
+		response, err := d.sendModbusRequest(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		more, next, err := parseDeviceID(request, response, objects)
+		if err != nil {
+			return nil, err
+		}
+		if !more || code == DeviceIDIndividual {
+			return objects, nil
+		}
+		// A slave that does not move on would be asked forever
+		if next <= objectID {
+			return nil, newError(ModbusInvalidResponse, request, response,
+				fmt.Errorf("invalid next object ID 0x%02X after object 0x%02X", next, objectID))
+		}
+		objectID = next
+	}
+}
+
+// parseDeviceID adds the objects of a Read Device Identification response
+// to objects and returns whether more follow, from which object ID
+func parseDeviceID(request, response []byte, objects map[byte]string) (more bool, next byte, err error) {
+	// MEI type, read device ID code, conformity level, more follows, next
+	// object ID and number of objects; responseLength has checked that the
+	// objects fill the rest of the frame
+	if response[2] != meiReadDeviceID || len(response) < 10 {
+		return false, 0, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid MEI type 0x%02X in response", response[2]))
+	}
+	if response[3] != request[3] {
+		return false, 0, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid read device ID code in response: got %d, expected %d", response[3], request[3]))
+	}
+
+	offset := 8
+	for i := 0; i < int(response[7]); i++ {
+		id, length := response[offset], int(response[offset+1])
+		objects[id] = string(response[offset+2 : offset+2+length])
+		offset += 2 + length
+	}
+	return response[5] == moreFollows, response[6], nil
+}
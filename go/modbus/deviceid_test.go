@@ -0,0 +1,92 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+// deviceIDResponse builds a Read Device Identification response holding
+// objects, in the order of ids
+func deviceIDResponse(request []byte, more bool, next byte, ids []byte, objects map[byte]string) []byte {
+	body := []byte{request[0], 0x2B, meiReadDeviceID, request[3], 0x01, 0, next, byte(len(ids))}
+	if more {
+		body[5] = moreFollows
+	}
+	for _, id := range ids {
+		body = append(body, id, byte(len(objects[id])))
+		body = append(body, objects[id]...)
+	}
+	return appendCRC(body)
+}
+
+func TestReadDeviceIdentificationContinued(t *testing.T) {
+	objects := map[byte]string{ObjectVendorName: "Acme", ObjectProductCode: "PLC-1", ObjectMajorMinorRevision: "2.1"}
+	transport := newFakeTransport(func(request []byte) []byte {
+		// The slave fits two objects into a response
+		if request[4] == 0 {
+			return deviceIDResponse(request, true, 2, []byte{0, 1}, objects)
+		}
+		return deviceIDResponse(request, false, 0, []byte{2}, objects)
+	})
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	got, err := d.ReadDeviceIdentification(1, DeviceIDBasic, 0)
+	if err != nil || !reflect.DeepEqual(got, objects) {
+		t.Fatalf("ReadDeviceIdentification = %q, %v; want %q", got, err, objects)
+	}
+	sent := transport.sent()
+	if len(sent) != 2 || sent[0][4] != 0 || sent[1][4] != 2 {
+		t.Errorf("sent % X, want requests from objects 0 and 2", sent)
+	}
+}
+
+func TestReadDeviceIdentificationStalled(t *testing.T) {
+	objects := map[byte]string{ObjectVendorName: "Acme"}
+	transport := newFakeTransport(func(request []byte) []byte {
+		// More follows, but from the object just asked for
+		return deviceIDResponse(request, true, request[4], []byte{0}, objects)
+	})
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	if _, err := d.ReadDeviceIdentification(1, DeviceIDBasic, 0); ErrorCode(err) != ModbusInvalidResponse {
+		t.Errorf("ReadDeviceIdentification error = %v, want ModbusInvalidResponse", err)
+	}
+	if n := len(transport.sent()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestReadDeviceIdentificationTruncated(t *testing.T) {
+	// The object claims 16 bytes but only 4 follow before the CRC
+	d := newTestDevice(t, newFakeTransport(respond(1, 0x2B, meiReadDeviceID, 0x01, 0x01, 0, 0, 1,
+		ObjectVendorName, 16, 'A', 'c', 'm', 'e')))
+	defer d.Close()
+
+	// The line goes silent before the frame is complete
+	if got, err := d.ReadDeviceIdentification(1, DeviceIDBasic, 0); ErrorCode(err) != ModbusInvalidResponse {
+		t.Errorf("ReadDeviceIdentification of a truncated object = %q, %v; want ModbusInvalidResponse", got, err)
+	}
+}
+
+func TestReadDeviceIdentificationIndividual(t *testing.T) {
+	objects := map[byte]string{ObjectProductName: "Pump"}
+	transport := newFakeTransport(func(request []byte) []byte {
+		// An individual read never continues, whatever the slave says
+		return deviceIDResponse(request, true, 5, []byte{ObjectProductName}, objects)
+	})
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	got, err := d.ReadDeviceIdentification(1, DeviceIDIndividual, ObjectProductName)
+	if err != nil || !reflect.DeepEqual(got, objects) {
+		t.Errorf("ReadDeviceIdentification = %q, %v; want %q", got, err, objects)
+	}
+	if n := len(transport.sent()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+	if _, err := d.ReadDeviceIdentification(1, DeviceIDIndividual+1, 0); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("ReadDeviceIdentification with code %d error = %v, want ModbusInvalidRequest", DeviceIDIndividual+1, err)
+	}
+}
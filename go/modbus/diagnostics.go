@@ -0,0 +1,241 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// DiagSubFunction selects a test or counter of the Diagnostics function
+type DiagSubFunction uint16
+
+const (
+	DiagReturnQueryData                DiagSubFunction = 0x00
+	DiagRestartCommunications          DiagSubFunction = 0x01
+	DiagReturnDiagnosticRegister       DiagSubFunction = 0x02
+	DiagForceListenOnly                DiagSubFunction = 0x04
+	DiagClearCounters                  DiagSubFunction = 0x0A
+	DiagReturnBusMessageCount          DiagSubFunction = 0x0B
+	DiagReturnBusCommErrorCount        DiagSubFunction = 0x0C
+	DiagReturnBusExceptionErrorCount   DiagSubFunction = 0x0D
+	DiagReturnServerMessageCount       DiagSubFunction = 0x0E
+	DiagReturnServerNoResponseCount    DiagSubFunction = 0x0F
+	DiagReturnServerNAKCount           DiagSubFunction = 0x10
+	DiagReturnServerBusyCount          DiagSubFunction = 0x11
+	DiagReturnBusCharacterOverrunCount DiagSubFunction = 0x12
+	DiagClearOverrunCounter            DiagSubFunction = 0x14
+)
+
+// restartClearLog makes Restart Communications Option clear the slave's
+// communications event log
+const restartClearLog = 0xFF00
+
+// isListenOnly reports whether request is a Force Listen Only Mode request,
+// which the slave does not answer
+func isListenOnly(request []byte) bool {
+	return request[1] == 0x08 && DiagSubFunction(binary.BigEndian.Uint16(request[2:4])) == DiagForceListenOnly
+}
+
+// isRestart reports whether request is a Restart Communications request,
+// which a slave in listen only mode carries out without answering
+func isRestart(request []byte) bool {
+	return request[1] == 0x08 && DiagSubFunction(binary.BigEndian.Uint16(request[2:4])) == DiagRestartCommunications
+}
+
+// Diagnostics sends sub-function sub with data (function 0x08) and returns
+// the data of the response. It returns nil data for DiagForceListenOnly,
+// which the slave does not answer, and for DiagRestartCommunications when
+// the slave does not answer in time.
+func (d *ModbusDevice) Diagnostics(slaveID byte, sub DiagSubFunction, data []byte) ([]byte, error) {
+	return d.DiagnosticsContext(context.Background(), slaveID, sub, data)
+}
+
+// DiagnosticsContext is Diagnostics with a context bounding the wait for the
+// bus and for the response
+func (d *ModbusDevice) DiagnosticsContext(ctx context.Context, slaveID byte, sub DiagSubFunction, data []byte) ([]byte, error) {
+	if len(data) > MaxDiagnosticData {
+		return nil, newError(ModbusInvalidRequest, []byte{slaveID, 0x08}, nil,
+			fmt.Errorf("invalid diagnostics data: %d bytes exceeds the %d byte limit", len(data), MaxDiagnosticData))
+	}
+
+	request := make([]byte, 4+len(data))
+	request[0] = slaveID
+	request[1] = 0x08
+	binary.BigEndian.PutUint16(request[2:], uint16(sub))
+	copy(request[4:], data)
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil || response == nil {
+		return nil, err
+	}
+	if !bytes.Equal(response[2:4], request[2:4]) {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid sub-function in response: got 0x%04X, expected 0x%04X",
+				binary.BigEndian.Uint16(response[2:4]), uint16(sub)))
+	}
+	return response[4 : len(response)-2], nil
+}
+
+// diagnosticsWord sends sub-function sub with a data word and returns the
+// word the slave answers with
+func (d *ModbusDevice) diagnosticsWord(ctx context.Context, slaveID byte, sub DiagSubFunction, word uint16) (uint16, error) {
+	data, err := d.DiagnosticsContext(ctx, slaveID, sub, binary.BigEndian.AppendUint16(nil, word))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 2 {
+		return 0, newError(ModbusInvalidResponse, []byte{slaveID, 0x08}, nil,
+			fmt.Errorf("invalid diagnostics data length in response: got %d, expected 2", len(data)))
+	}
+	return binary.BigEndian.Uint16(data), nil
+}
+
+// ReturnQueryData is a loopback test: the slave returns data unchanged, and
+// any difference fails the call with a *MismatchError
+func (d *ModbusDevice) ReturnQueryData(slaveID byte, data []byte) error {
+	return d.ReturnQueryDataContext(context.Background(), slaveID, data)
+}
+
+// ReturnQueryDataContext is ReturnQueryData with a context bounding the wait
+// for the bus and for the response
+func (d *ModbusDevice) ReturnQueryDataContext(ctx context.Context, slaveID byte, data []byte) error {
+	echo, err := d.DiagnosticsContext(ctx, slaveID, DiagReturnQueryData, data)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(echo, data) {
+		request := append([]byte{slaveID, 0x08, 0, 0}, data...)
+		response := appendCRC(append([]byte{slaveID, 0x08, 0, 0}, echo...))
+		return newError(ModbusInvalidResponse, request, response, &MismatchError{
+			Request:  appendCRC(request),
+			Response: response,
+		})
+	}
+	return nil
+}
+
+// RestartCommunications restarts the serial port of the slave and takes it
+// out of listen only mode, clearing its communications event log as well
+// when clearLog is set. A slave in listen only mode does not answer, so the
+// call succeeds once the response timeout passes in silence; it is not
+// retried.
+func (d *ModbusDevice) RestartCommunications(slaveID byte, clearLog bool) error {
+	return d.RestartCommunicationsContext(context.Background(), slaveID, clearLog)
+}
+
+// RestartCommunicationsContext is RestartCommunications with a context
+// bounding the wait for the bus and for the response
+func (d *ModbusDevice) RestartCommunicationsContext(ctx context.Context, slaveID byte, clearLog bool) error {
+	word := uint16(0)
+	if clearLog {
+		word = restartClearLog
+	}
+	_, err := d.DiagnosticsContext(ctx, slaveID, DiagRestartCommunications, binary.BigEndian.AppendUint16(nil, word))
+	return err
+}
+
+// DiagnosticRegister returns the slave's 16-bit diagnostic register, whose
+// bits the device defines
+func (d *ModbusDevice) DiagnosticRegister(slaveID byte) (uint16, error) {
+	return d.DiagnosticRegisterContext(context.Background(), slaveID)
+}
+
+// DiagnosticRegisterContext is DiagnosticRegister with a context bounding
+// the wait for the bus and for the response
+func (d *ModbusDevice) DiagnosticRegisterContext(ctx context.Context, slaveID byte) (uint16, error) {
+	return d.diagnosticsWord(ctx, slaveID, DiagReturnDiagnosticRegister, 0)
+}
+
+// ForceListenOnly makes the slave stop answering, and stop acting on
+// anything but RestartCommunications. It returns once the request is sent,
+// since no response follows.
+func (d *ModbusDevice) ForceListenOnly(slaveID byte) error {
+	return d.ForceListenOnlyContext(context.Background(), slaveID)
+}
+
+// ForceListenOnlyContext is ForceListenOnly with a context bounding the wait
+// for the bus
+func (d *ModbusDevice) ForceListenOnlyContext(ctx context.Context, slaveID byte) error {
+	_, err := d.DiagnosticsContext(ctx, slaveID, DiagForceListenOnly, []byte{0, 0})
+	return err
+}
+
+// ClearCounters clears the slave's diagnostic counters and diagnostic
+// register
+func (d *ModbusDevice) ClearCounters(slaveID byte) error {
+	return d.ClearCountersContext(context.Background(), slaveID)
+}
+
+// ClearCountersContext is ClearCounters with a context bounding the wait for
+// the bus and for the response
+func (d *ModbusDevice) ClearCountersContext(ctx context.Context, slaveID byte) error {
+	_, err := d.diagnosticsWord(ctx, slaveID, DiagClearCounters, 0)
+	return err
+}
+
+// ClearOverrunCounter clears the slave's character overrun counter and
+// error flag
+func (d *ModbusDevice) ClearOverrunCounter(slaveID byte) error {
+	return d.ClearOverrunCounterContext(context.Background(), slaveID)
+}
+
+// ClearOverrunCounterContext is ClearOverrunCounter with a context bounding
+// the wait for the bus and for the response
+func (d *ModbusDevice) ClearOverrunCounterContext(ctx context.Context, slaveID byte) error {
+	_, err := d.diagnosticsWord(ctx, slaveID, DiagClearOverrunCounter, 0)
+	return err
+}
+
+// DiagnosticCounters are the counters a slave keeps since it last started,
+// or since they were cleared
+type DiagnosticCounters struct {
+	// BusMessages counts the messages the slave saw on the bus
+	BusMessages uint16 `json:"busMessages"`
+	// BusCommErrors counts the messages that failed their CRC check
+	BusCommErrors uint16 `json:"busCommErrors"`
+	// BusExceptionErrors counts the exception responses the slave sent
+	BusExceptionErrors uint16 `json:"busExceptionErrors"`
+	// ServerMessages counts the messages addressed to the slave
+	ServerMessages uint16 `json:"serverMessages"`
+	// ServerNoResponses counts the messages the slave did not answer
+	ServerNoResponses uint16 `json:"serverNoResponses"`
+	// ServerNAKs counts the Negative Acknowledge exceptions it sent
+	ServerNAKs uint16 `json:"serverNaks"`
+	// ServerBusy counts the Server Device Busy exceptions it sent
+	ServerBusy uint16 `json:"serverBusy"`
+	// BusCharacterOverruns counts the messages lost to character overruns
+	BusCharacterOverruns uint16 `json:"busCharacterOverruns"`
+}
+
+// ReadDiagnosticCounters reads all of the slave's diagnostic counters, one
+// transaction each
+func (d *ModbusDevice) ReadDiagnosticCounters(slaveID byte) (DiagnosticCounters, error) {
+	return d.ReadDiagnosticCountersContext(context.Background(), slaveID)
+}
+
+// ReadDiagnosticCountersContext is ReadDiagnosticCounters with a context
+// bounding the wait for the bus and for the responses
+func (d *ModbusDevice) ReadDiagnosticCountersContext(ctx context.Context, slaveID byte) (DiagnosticCounters, error) {
+	var c DiagnosticCounters
+	for _, counter := range []struct {
+		sub   DiagSubFunction
+		value *uint16
+	}{
+		{DiagReturnBusMessageCount, &c.BusMessages},
+		{DiagReturnBusCommErrorCount, &c.BusCommErrors},
+		{DiagReturnBusExceptionErrorCount, &c.BusExceptionErrors},
+		{DiagReturnServerMessageCount, &c.ServerMessages},
+		{DiagReturnServerNoResponseCount, &c.ServerNoResponses},
+		{DiagReturnServerNAKCount, &c.ServerNAKs},
+		{DiagReturnServerBusyCount, &c.ServerBusy},
+		{DiagReturnBusCharacterOverrunCount, &c.BusCharacterOverruns},
+	} {
+		value, err := d.diagnosticsWord(ctx, slaveID, counter.sub, 0)
+		if err != nil {
+			return DiagnosticCounters{}, err
+		}
+		*counter.value = value
+	}
+	return c, nil
+}
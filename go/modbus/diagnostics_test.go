@@ -0,0 +1,58 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRestartCommunications(t *testing.T) {
+	transport := newFakeTransport(func(request []byte) []byte { return request })
+	d := newTestDevice(t, transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	defer d.Close()
+
+	if err := d.RestartCommunications(1, true); err != nil {
+		t.Fatalf("RestartCommunications: %v", err)
+	}
+	want := appendCRC([]byte{1, 0x08, 0x00, 0x01, 0xFF, 0x00})
+	if sent := transport.sent(); len(sent) != 1 || !bytes.Equal(sent[0], want) {
+		t.Errorf("sent % X, want % X", sent, want)
+	}
+}
+
+func TestRestartCommunicationsListenOnly(t *testing.T) {
+	transport := newFakeTransport(nil)
+	d := newTestDevice(t, transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	defer d.Close()
+
+	start := time.Now()
+	if err := d.RestartCommunications(1, false); err != nil {
+		t.Fatalf("RestartCommunications of a silent slave: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("returned after %v, want one response timeout", elapsed)
+	}
+	if n := len(transport.sent()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+
+	// Other sub-functions still time out
+	if _, err := d.DiagnosticRegister(1); !errors.Is(err, ErrTimeout) {
+		t.Errorf("DiagnosticRegister error = %v, want ErrTimeout", err)
+	}
+}
+
+func TestForceListenOnly(t *testing.T) {
+	transport := newFakeTransport(nil)
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	start := time.Now()
+	if err := d.ForceListenOnly(1); err != nil {
+		t.Fatalf("ForceListenOnly: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("returned after %v, want without waiting for a response", elapsed)
+	}
+}
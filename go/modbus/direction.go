@@ -0,0 +1,128 @@
+package modbus
+
+import (
+	"fmt"
+	"time"
+)
+
+// DirectionMode selects how the transceiver's driver and receiver are
+// enabled
+type DirectionMode int
+
+const (
+	// DirectionSplit drives DE and /RE from two separate GPIOs
+	DirectionSplit DirectionMode = iota
+
+	// DirectionTied drives DE and /RE tied together from one GPIO
+	DirectionTied
+
+	// DirectionRTS drives DE from the UART RTS line (TIOCMSET)
+	DirectionRTS
+
+	// DirectionNone leaves direction to an auto-direction transceiver
+	DirectionNone
+)
+
+// String returns the name used by the -de flag and the JS options
+func (m DirectionMode) String() string {
+	switch m {
+	case DirectionSplit:
+		return "split"
+	case DirectionTied:
+		return "tied"
+	case DirectionRTS:
+		return "rts"
+	case DirectionNone:
+		return "none"
+	default:
+		return fmt.Sprintf("DirectionMode(%d)", int(m))
+	}
+}
+
+// ParseDirectionMode parses the name returned by DirectionMode.String
+func ParseDirectionMode(s string) (DirectionMode, error) {
+	for _, m := range []DirectionMode{DirectionSplit, DirectionTied, DirectionRTS, DirectionNone} {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown direction mode %q", s)
+}
+
+// DirectionConfig describes the direction control wiring of a board
+type DirectionConfig struct {
+	Mode DirectionMode
+
+	// InvertDE drives DE (the tied pin, or RTS) low to transmit, for
+	// boards with an inverter in front of the transceiver
+	InvertDE bool
+
+	// InvertRE drives /RE high to receive
+	InvertRE bool
+
+	// Setup is waited after enabling the driver before the first bit is
+	// sent; Hold is waited after the last bit before releasing it
+	Setup time.Duration
+	Hold  time.Duration
+}
+
+// WithDirectionConfig selects the direction control mode, polarity and
+// setup/hold times used with the dePin/rePin lines
+func WithDirectionConfig(cfg DirectionConfig) DeviceOption {
+	return func(c *deviceConfig) {
+		c.directionConfig = cfg
+	}
+}
+
+// rtsSetter is implemented by transports that can drive the RTS line
+type rtsSetter interface {
+	SetRTS(asserted bool) error
+}
+
+// rtsDirection drives DE from the RTS modem control line of the port
+type rtsDirection struct {
+	port   rtsSetter
+	invert bool
+}
+
+// NewRTSDirection drives DE from the RTS line of t, asserting RTS to
+// transmit unless invert is set
+func NewRTSDirection(t Transport, invert bool) (DirectionController, error) {
+	port, ok := t.(rtsSetter)
+	if !ok {
+		return nil, fmt.Errorf("transport cannot drive RTS")
+	}
+	return &rtsDirection{port: port, invert: invert}, nil
+}
+
+func (r *rtsDirection) EnableTX() error { return r.port.SetRTS(!r.invert) }
+func (r *rtsDirection) EnableRX() error { return r.port.SetRTS(r.invert) }
+func (r *rtsDirection) Close() error    { return nil }
+
+// timedDirection adds setup and hold times around another controller
+type timedDirection struct {
+	DirectionController
+	setup time.Duration
+	hold  time.Duration
+}
+
+func (t *timedDirection) EnableTX() error {
+	if err := t.DirectionController.EnableTX(); err != nil {
+		return err
+	}
+	time.Sleep(t.setup)
+	return nil
+}
+
+func (t *timedDirection) EnableRX() error {
+	time.Sleep(t.hold)
+	return t.DirectionController.EnableRX()
+}
+
+// withSetupHold wraps dc when cfg asks for setup or hold times
+func withSetupHold(dc DirectionController, cfg DirectionConfig) DirectionController {
+	if cfg.Setup == 0 && cfg.Hold == 0 {
+		return dc
+	}
+	return &timedDirection{DirectionController: dc, setup: cfg.Setup, hold: cfg.Hold}
+}
@@ -0,0 +1,160 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ModbusError classifies why a call failed. Every error returned by device
+// calls belongs to one class, see ErrorCode, and errors.Is(err, class)
+// reports whether it does.
+type ModbusError int
+
+const (
+	ModbusSuccess ModbusError = iota
+	ModbusCRCError
+	ModbusTimeoutError
+	ModbusInvalidResponse
+	ModbusSerialError
+	// ModbusExceptionResponse is a slave's exception response, see
+	// ExceptionError
+	ModbusExceptionResponse
+	// ModbusCanceled means the context of the call was canceled
+	ModbusCanceled
+	// ModbusInvalidRequest means the call was rejected without touching
+	// the bus
+	ModbusInvalidRequest
+)
+
+// Error describes the class
+func (e ModbusError) Error() string {
+	switch e {
+	case ModbusSuccess:
+		return "success"
+	case ModbusCRCError:
+		return "CRC error"
+	case ModbusTimeoutError:
+		return "timeout waiting for response"
+	case ModbusInvalidResponse:
+		return "invalid response"
+	case ModbusSerialError:
+		return "serial port error"
+	case ModbusExceptionResponse:
+		return "exception response"
+	case ModbusCanceled:
+		return "canceled"
+	case ModbusInvalidRequest:
+		return "invalid request"
+	default:
+		return fmt.Sprintf("Modbus error %d", int(e))
+	}
+}
+
+// Errors returned by device calls, possibly wrapped with details; test for
+// them with errors.Is. A slave's exception response is an *ExceptionError,
+// everything else an *Error.
+var (
+	// ErrTimeout means the slave did not answer in time. Retried by the
+	// default RetryPolicy.
+	ErrTimeout error = ModbusTimeoutError
+
+	// ErrCRC means a response was corrupted on the line. Retried by the
+	// default RetryPolicy.
+	ErrCRC error = ModbusCRCError
+
+	// ErrCollision means the echo of a request differed from what was sent,
+	// see SerialOptions.LocalEcho, so another station was sending at the
+	// same time. Retried by the default RetryPolicy.
+	ErrCollision = errors.New("bus collision")
+
+	// ErrDisconnected means the serial port is gone and has not been
+	// reopened yet, see WithReconnect
+	ErrDisconnected = errors.New("serial port disconnected")
+
+	// ErrClosed means the device was closed
+	ErrClosed = errors.New("device is closed")
+)
+
+// Error is a failed call to a slave. Err holds the details, and Frame the
+// raw response as far as it was received, CRC included.
+type Error struct {
+	Code         ModbusError
+	SlaveID      byte
+	FunctionCode byte
+	Frame        []byte
+	Err          error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Code.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the class of e
+func (e *Error) Is(target error) bool {
+	code, ok := target.(ModbusError)
+	return ok && code == e.Code
+}
+
+// MismatchError is the cause of an *Error when the response to a write
+// does not repeat the address, value or quantity written. Request and
+// Response are the frames sent and received, CRC included.
+type MismatchError struct {
+	Request  []byte
+	Response []byte
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("response does not match request: sent % X, received % X", e.Request, e.Response)
+}
+
+// newError builds the *Error for a failure of request, which frame answered
+// if it is not nil
+func newError(code ModbusError, request, frame []byte, err error) *Error {
+	return &Error{
+		Code:         code,
+		SlaveID:      request[0],
+		FunctionCode: request[1],
+		Frame:        frame,
+		Err:          err,
+	}
+}
+
+// contextError builds the *Error for request being cut short by err from
+// its context
+func contextError(request []byte, err error) *Error {
+	code := ModbusCanceled
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = ModbusTimeoutError
+	}
+	return newError(code, request, nil, err)
+}
+
+// ErrorCode returns the class of err: ModbusSuccess for nil, and
+// ModbusSerialError for errors that did not come from a device call.
+// Context errors count as ModbusCanceled or, past a deadline,
+// ModbusTimeoutError.
+func ErrorCode(err error) ModbusError {
+	var e *Error
+	var exc *ExceptionError
+	switch {
+	case err == nil:
+		return ModbusSuccess
+	case errors.As(err, &exc):
+		return ModbusExceptionResponse
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return ModbusTimeoutError
+	case errors.Is(err, context.Canceled):
+		return ModbusCanceled
+	}
+	return ModbusSerialError
+}
@@ -0,0 +1,72 @@
+package modbus
+
+import "fmt"
+
+// ExceptionCode is the one-byte code carried in a Modbus exception response
+type ExceptionCode byte
+
+const (
+	ExceptionIllegalFunction                    ExceptionCode = 0x01
+	ExceptionIllegalDataAddress                 ExceptionCode = 0x02
+	ExceptionIllegalDataValue                   ExceptionCode = 0x03
+	ExceptionServerDeviceFailure                ExceptionCode = 0x04
+	ExceptionAcknowledge                        ExceptionCode = 0x05
+	ExceptionServerDeviceBusy                   ExceptionCode = 0x06
+	ExceptionNegativeAcknowledge                ExceptionCode = 0x07
+	ExceptionMemoryParityError                  ExceptionCode = 0x08
+	ExceptionGatewayPathUnavailable             ExceptionCode = 0x0A
+	ExceptionGatewayTargetDeviceFailedToRespond ExceptionCode = 0x0B
+)
+
+// exceptionFlag is OR-ed into the function code of an exception response
+const exceptionFlag = 0x80
+
+// exceptionFrameLength is slave ID, function code, exception code and CRC
+const exceptionFrameLength = 5
+
+// String returns the name the Modbus specification gives the code
+func (c ExceptionCode) String() string {
+	switch c {
+	case ExceptionIllegalFunction:
+		return "Illegal Function"
+	case ExceptionIllegalDataAddress:
+		return "Illegal Data Address"
+	case ExceptionIllegalDataValue:
+		return "Illegal Data Value"
+	case ExceptionServerDeviceFailure:
+		return "Server Device Failure"
+	case ExceptionAcknowledge:
+		return "Acknowledge"
+	case ExceptionServerDeviceBusy:
+		return "Server Device Busy"
+	case ExceptionNegativeAcknowledge:
+		return "Negative Acknowledge"
+	case ExceptionMemoryParityError:
+		return "Memory Parity Error"
+	case ExceptionGatewayPathUnavailable:
+		return "Gateway Path Unavailable"
+	case ExceptionGatewayTargetDeviceFailedToRespond:
+		return "Gateway Target Device Failed to Respond"
+	default:
+		return fmt.Sprintf("Unknown Exception 0x%02X", byte(c))
+	}
+}
+
+// ExceptionError is returned when a slave answers with an exception
+// response. Frame is the raw response, CRC included.
+type ExceptionError struct {
+	SlaveID      byte
+	FunctionCode byte
+	Code         ExceptionCode
+	Frame        []byte
+}
+
+func (e *ExceptionError) Error() string {
+	return fmt.Sprintf("Modbus exception 0x%02X (%s) from slave %d for function 0x%02X",
+		byte(e.Code), e.Code, e.SlaveID, e.FunctionCode)
+}
+
+// Is matches the ModbusExceptionResponse class
+func (e *ExceptionError) Is(target error) bool {
+	return target == ModbusExceptionResponse
+}
@@ -0,0 +1,246 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// fileReferenceType is the reference type every file sub-request carries
+const fileReferenceType = 0x06
+
+// maxFileReadBytes and maxFileWriteBytes are the largest byte counts the
+// specification allows in Read File Record responses and Write File Record
+// requests
+const (
+	maxFileReadBytes  = 0xF5
+	maxFileWriteBytes = 0xFB
+)
+
+// FileRecordRequest asks for Count registers from record Record of file File
+type FileRecordRequest struct {
+	File   uint16
+	Record uint16
+	Count  uint16
+}
+
+// FileRecord is a run of registers from record Record of file File
+type FileRecord struct {
+	File   uint16
+	Record uint16
+	Data   []uint16
+}
+
+// checkFileRecord rejects a sub-request for count records that addresses
+// file 0, exceeds limit or runs past the last record of the file
+func checkFileRecord(slaveID, functionCode byte, file, record uint16, count, limit int) error {
+	var err error
+	switch {
+	case file == 0:
+		err = fmt.Errorf("invalid file number 0: must be 1 to %d", 0xFFFF)
+	case count < 1 || count > limit:
+		err = fmt.Errorf("invalid quantity %d: function 0x%02X takes 1 to %d", count, functionCode, limit)
+	case int(record)+count > MaxFileRecordNumber+1:
+		err = fmt.Errorf("invalid record range: %d records from record %d run past record %d", count, record, MaxFileRecordNumber)
+	}
+	if err != nil {
+		return newError(ModbusInvalidRequest, []byte{slaveID, functionCode}, nil, err)
+	}
+	return nil
+}
+
+// appendFileSubRequest appends the reference type, file number, record
+// number and record length of a sub-request
+func appendFileSubRequest(request []byte, file, record uint16, count int) []byte {
+	request = append(request, fileReferenceType)
+	request = binary.BigEndian.AppendUint16(request, file)
+	request = binary.BigEndian.AppendUint16(request, record)
+	return binary.BigEndian.AppendUint16(request, uint16(count))
+}
+
+// ReadFileRecords reads one run of registers per request in a single
+// transaction (function 0x14) and returns them in the same order. All the
+// runs together must fit into one response.
+func (d *ModbusDevice) ReadFileRecords(slaveID byte, requests []FileRecordRequest) ([][]uint16, error) {
+	return d.ReadFileRecordsContext(context.Background(), slaveID, requests)
+}
+
+// ReadFileRecordsContext is ReadFileRecords with a context bounding the wait
+// for the bus and for the response
+func (d *ModbusDevice) ReadFileRecordsContext(ctx context.Context, slaveID byte, requests []FileRecordRequest) ([][]uint16, error) {
+	request := []byte{slaveID, 0x14, 0}
+	// Each sub-response is a length byte, the reference type and the data
+	byteCount := 0
+	for _, r := range requests {
+		if err := checkFileRecord(slaveID, 0x14, r.File, r.Record, int(r.Count), MaxFileReadRegisters); err != nil {
+			return nil, err
+		}
+		request = appendFileSubRequest(request, r.File, r.Record, int(r.Count))
+		byteCount += 2 + 2*int(r.Count)
+	}
+	switch {
+	case len(requests) == 0:
+		return nil, newError(ModbusInvalidRequest, request, nil, errors.New("invalid file request: no sub-requests"))
+	case len(request)-3 > maxFileReadBytes:
+		return nil, newError(ModbusInvalidRequest, request, nil,
+			fmt.Errorf("invalid file request: %d sub-requests exceed the %d byte limit", len(requests), maxFileReadBytes))
+	case byteCount > maxFileReadBytes:
+		return nil, newError(ModbusInvalidRequest, request, nil,
+			fmt.Errorf("invalid file request: the response would take %d bytes, exceeding the %d byte limit", byteCount, maxFileReadBytes))
+	}
+	request[2] = byte(len(request) - 3)
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if int(response[2]) != byteCount {
+		return nil, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected %d", response[2], byteCount))
+	}
+
+	result := make([][]uint16, len(requests))
+	offset := 3
+	for i, r := range requests {
+		length, refType := int(response[offset]), response[offset+1]
+		if length != 1+2*int(r.Count) || refType != fileReferenceType {
+			return nil, newError(ModbusInvalidResponse, request, response,
+				fmt.Errorf("invalid sub-response %d: length %d and reference type %d, expected %d and %d",
+					i, length, refType, 1+2*int(r.Count), fileReferenceType))
+		}
+		result[i] = make([]uint16, r.Count)
+		for j := range result[i] {
+			result[i][j] = binary.BigEndian.Uint16(response[offset+2+2*j:])
+		}
+		offset += 1 + length
+	}
+	return result, nil
+}
+
+// WriteFileRecords writes each record in a single transaction (function
+// 0x15). All the records together must fit into one request.
+func (d *ModbusDevice) WriteFileRecords(slaveID byte, records []FileRecord) error {
+	return d.WriteFileRecordsContext(context.Background(), slaveID, records)
+}
+
+// WriteFileRecordsContext is WriteFileRecords with a context bounding the
+// wait for the bus and for the response
+func (d *ModbusDevice) WriteFileRecordsContext(ctx context.Context, slaveID byte, records []FileRecord) error {
+	request := []byte{slaveID, 0x15, 0}
+	for _, r := range records {
+		if err := checkFileRecord(slaveID, 0x15, r.File, r.Record, len(r.Data), MaxFileWriteRegisters); err != nil {
+			return err
+		}
+		request = appendFileSubRequest(request, r.File, r.Record, len(r.Data))
+		for _, value := range r.Data {
+			request = binary.BigEndian.AppendUint16(request, value)
+		}
+	}
+	switch {
+	case len(records) == 0:
+		return newError(ModbusInvalidRequest, request, nil, errors.New("invalid file request: no sub-requests"))
+	case len(request)-3 > maxFileWriteBytes:
+		return newError(ModbusInvalidRequest, request, nil,
+			fmt.Errorf("invalid file request: %d bytes of sub-requests exceed the %d byte limit", len(request)-3, maxFileWriteBytes))
+	}
+	request[2] = byte(len(request) - 3)
+
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+	return checkEcho(request, response, len(request))
+}
+
+// FileTransferOptions tunes DownloadFile and UploadFile
+type FileTransferOptions struct {
+	// ChunkSize is the number of registers moved per transaction. Zero
+	// takes as many as one request carries; devices that store records
+	// of a fixed size may need a multiple of it.
+	ChunkSize int
+
+	// Progress, if set, is called after each chunk with the number of
+	// registers transferred so far and in total
+	Progress func(done, total int)
+}
+
+// chunkSize returns the registers per transaction, up to limit
+func (o FileTransferOptions) chunkSize(request []byte, limit int) (int, error) {
+	switch {
+	case o.ChunkSize == 0:
+		return limit, nil
+	case o.ChunkSize < 0 || o.ChunkSize > limit:
+		return 0, newError(ModbusInvalidRequest, request, nil,
+			fmt.Errorf("invalid chunk size %d: function 0x%02X takes 1 to %d registers", o.ChunkSize, request[1], limit))
+	}
+	return o.ChunkSize, nil
+}
+
+// DownloadFile reads count registers of file from startRecord on, one
+// chunk per transaction. On failure it returns the error of the chunk
+// that failed.
+func (d *ModbusDevice) DownloadFile(slaveID byte, file, startRecord uint16, count int, opts FileTransferOptions) ([]uint16, error) {
+	return d.DownloadFileContext(context.Background(), slaveID, file, startRecord, count, opts)
+}
+
+// DownloadFileContext is DownloadFile with a context bounding the whole
+// transfer
+func (d *ModbusDevice) DownloadFileContext(ctx context.Context, slaveID byte, file, startRecord uint16, count int, opts FileTransferOptions) ([]uint16, error) {
+	if err := checkFileRecord(slaveID, 0x14, file, startRecord, count, MaxFileRecordNumber+1); err != nil {
+		return nil, err
+	}
+	chunk, err := opts.chunkSize([]byte{slaveID, 0x14}, MaxFileReadRegisters)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]uint16, 0, count)
+	for len(data) < count {
+		n := min(chunk, count-len(data))
+		records, err := d.ReadFileRecordsContext(ctx, slaveID, []FileRecordRequest{
+			{File: file, Record: startRecord + uint16(len(data)), Count: uint16(n)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, records[0]...)
+		if opts.Progress != nil {
+			opts.Progress(len(data), count)
+		}
+	}
+	return data, nil
+}
+
+// UploadFile writes data to file from startRecord on, one chunk per
+// transaction. On failure the chunks before the one that failed have been
+// written.
+func (d *ModbusDevice) UploadFile(slaveID byte, file, startRecord uint16, data []uint16, opts FileTransferOptions) error {
+	return d.UploadFileContext(context.Background(), slaveID, file, startRecord, data, opts)
+}
+
+// UploadFileContext is UploadFile with a context bounding the whole transfer
+func (d *ModbusDevice) UploadFileContext(ctx context.Context, slaveID byte, file, startRecord uint16, data []uint16, opts FileTransferOptions) error {
+	if err := checkFileRecord(slaveID, 0x15, file, startRecord, len(data), MaxFileRecordNumber+1); err != nil {
+		return err
+	}
+	chunk, err := opts.chunkSize([]byte{slaveID, 0x15}, MaxFileWriteRegisters)
+	if err != nil {
+		return err
+	}
+
+	for done := 0; done < len(data); {
+		n := min(chunk, len(data)-done)
+		err := d.WriteFileRecordsContext(ctx, slaveID, []FileRecord{
+			{File: file, Record: startRecord + uint16(done), Data: data[done : done+n]},
+		})
+		if err != nil {
+			return err
+		}
+		done += n
+		if opts.Progress != nil {
+			opts.Progress(done, len(data))
+		}
+	}
+	return nil
+}
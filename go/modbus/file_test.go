@@ -0,0 +1,179 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// fileSlave builds a slave that serves Read and Write File Record requests
+// from files, keyed by file and record number
+func fileSlave(files map[[2]uint16]uint16) func([]byte) []byte {
+	return func(request []byte) []byte {
+		subRequests := request[3 : 3+int(request[2])]
+		switch request[1] {
+		case 0x14:
+			body := []byte{request[0], 0x14, 0}
+			for ; len(subRequests) >= 7; subRequests = subRequests[7:] {
+				file, record := binary.BigEndian.Uint16(subRequests[1:]), binary.BigEndian.Uint16(subRequests[3:])
+				count := binary.BigEndian.Uint16(subRequests[5:])
+				body = append(body, byte(1+2*count), fileReferenceType)
+				for i := range count {
+					body = binary.BigEndian.AppendUint16(body, files[[2]uint16{file, record + i}])
+				}
+			}
+			body[2] = byte(len(body) - 3)
+			return appendCRC(body)
+		case 0x15:
+			for len(subRequests) >= 7 {
+				file, record := binary.BigEndian.Uint16(subRequests[1:]), binary.BigEndian.Uint16(subRequests[3:])
+				count := binary.BigEndian.Uint16(subRequests[5:])
+				for i := range count {
+					files[[2]uint16{file, record + i}] = binary.BigEndian.Uint16(subRequests[7+2*i:])
+				}
+				subRequests = subRequests[7+2*count:]
+			}
+			return request
+		}
+		return nil
+	}
+}
+
+// chunks returns the record number and count of the first sub-request of
+// each request sent
+func chunks(requests [][]byte) [][2]int {
+	var result [][2]int
+	for _, r := range requests {
+		result = append(result, [2]int{int(binary.BigEndian.Uint16(r[6:])), int(binary.BigEndian.Uint16(r[8:]))})
+	}
+	return result
+}
+
+func TestDownloadFile(t *testing.T) {
+	files := make(map[[2]uint16]uint16)
+	for i := range uint16(300) {
+		files[[2]uint16{4, 10 + i}] = i * 3
+	}
+	for _, tc := range []struct {
+		chunkSize int
+		chunks    [][2]int
+	}{
+		{0, [][2]int{{10, 121}, {131, 121}, {252, 58}}},
+		{100, [][2]int{{10, 100}, {110, 100}, {210, 100}}},
+	} {
+		transport := newFakeTransport(fileSlave(files))
+		d := newTestDevice(t, transport)
+		defer d.Close()
+
+		var progress [][2]int
+		data, err := d.DownloadFile(1, 4, 10, 300, FileTransferOptions{
+			ChunkSize: tc.chunkSize,
+			Progress:  func(done, total int) { progress = append(progress, [2]int{done, total}) },
+		})
+		if err != nil {
+			t.Fatalf("DownloadFile with chunk size %d: %v", tc.chunkSize, err)
+		}
+		for i, v := range data {
+			if v != uint16(i*3) {
+				t.Fatalf("register %d = %d, want %d", i, v, i*3)
+			}
+		}
+		if got := chunks(transport.sent()); !reflect.DeepEqual(got, tc.chunks) {
+			t.Errorf("chunk size %d: read chunks %v, want %v", tc.chunkSize, got, tc.chunks)
+		}
+		var want [][2]int
+		for _, c := range tc.chunks {
+			want = append(want, [2]int{c[0] + c[1] - 10, 300})
+		}
+		if !reflect.DeepEqual(progress, want) {
+			t.Errorf("chunk size %d: progress %v, want %v", tc.chunkSize, progress, want)
+		}
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	data := make([]uint16, 300)
+	for i := range data {
+		data[i] = uint16(i * 7)
+	}
+	for _, tc := range []struct {
+		chunkSize int
+		chunks    [][2]int
+	}{
+		{0, [][2]int{{0, 122}, {122, 122}, {244, 56}}},
+		{100, [][2]int{{0, 100}, {100, 100}, {200, 100}}},
+	} {
+		files := make(map[[2]uint16]uint16)
+		transport := newFakeTransport(fileSlave(files))
+		d := newTestDevice(t, transport)
+		defer d.Close()
+
+		var progress []int
+		err := d.UploadFile(1, 2, 0, data, FileTransferOptions{
+			ChunkSize: tc.chunkSize,
+			Progress:  func(done, total int) { progress = append(progress, done) },
+		})
+		if err != nil {
+			t.Fatalf("UploadFile with chunk size %d: %v", tc.chunkSize, err)
+		}
+		for i, v := range data {
+			if got := files[[2]uint16{2, uint16(i)}]; got != v {
+				t.Fatalf("record %d = %d, want %d", i, got, v)
+			}
+		}
+		if got := chunks(transport.sent()); !reflect.DeepEqual(got, tc.chunks) {
+			t.Errorf("chunk size %d: wrote chunks %v, want %v", tc.chunkSize, got, tc.chunks)
+		}
+		var want []int
+		for _, c := range tc.chunks {
+			want = append(want, c[0]+c[1])
+		}
+		if !reflect.DeepEqual(progress, want) {
+			t.Errorf("chunk size %d: progress %v, want %v", tc.chunkSize, progress, want)
+		}
+	}
+}
+
+func TestFileTransferChunkSize(t *testing.T) {
+	transport := newFakeTransport(fileSlave(make(map[[2]uint16]uint16)))
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	if _, err := d.DownloadFile(1, 1, 0, 10, FileTransferOptions{ChunkSize: MaxFileReadRegisters + 1}); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("DownloadFile with chunk size %d error = %v, want ModbusInvalidRequest", MaxFileReadRegisters+1, err)
+	}
+	if err := d.UploadFile(1, 1, 0, make([]uint16, 10), FileTransferOptions{ChunkSize: -1}); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("UploadFile with chunk size -1 error = %v, want ModbusInvalidRequest", err)
+	}
+	if _, err := d.DownloadFile(1, 1, MaxFileRecordNumber, 2, FileTransferOptions{}); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("DownloadFile past record %d error = %v, want ModbusInvalidRequest", MaxFileRecordNumber, err)
+	}
+	if n := len(transport.sent()); n != 0 {
+		t.Errorf("sent %d requests for invalid transfers", n)
+	}
+}
+
+func TestReadFileRecords(t *testing.T) {
+	files := map[[2]uint16]uint16{{1, 0}: 0x1111, {1, 1}: 0x2222, {3, 7}: 0x3333}
+	d := newTestDevice(t, newFakeTransport(fileSlave(files)))
+	defer d.Close()
+
+	got, err := d.ReadFileRecords(1, []FileRecordRequest{{File: 1, Record: 0, Count: 2}, {File: 3, Record: 7, Count: 1}})
+	if want := [][]uint16{{0x1111, 0x2222}, {0x3333}}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadFileRecords = %v, %v; want %v", got, err, want)
+	}
+}
+
+func TestReadFileRecordsBadSubResponse(t *testing.T) {
+	// The byte count matches, but the first sub-response claims one
+	// register too many and the second one too few
+	d := newTestDevice(t, newFakeTransport(respond(1, 0x14, 10,
+		6, fileReferenceType, 0x11, 0x11, 0x22, 0x22,
+		2, fileReferenceType, 0x33, 0x33)))
+	defer d.Close()
+
+	_, err := d.ReadFileRecords(1, []FileRecordRequest{{File: 1, Count: 1}, {File: 3, Count: 2}})
+	if ErrorCode(err) != ModbusInvalidResponse {
+		t.Errorf("ReadFileRecords error = %v, want ModbusInvalidResponse", err)
+	}
+}
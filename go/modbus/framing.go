@@ -0,0 +1,243 @@
+package modbus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// maxFrameLength is the largest RTU frame allowed by the specification
+const maxFrameLength = 256
+
+// responseLength works out how long the response to request is from the
+// bytes of it received so far. When known is true, length is the full frame
+// length including the CRC. Otherwise length is the number of bytes frame
+// must hold before the length can be determined, or 0 when the function
+// code has no length encoding and the frame can only be ended by silence.
+func responseLength(request, frame []byte) (length int, known bool) {
+	if len(frame) < 2 {
+		return 2, false
+	}
+
+	functionCode := frame[1]
+	if functionCode&exceptionFlag != 0 {
+		return exceptionFrameLength, true
+	}
+
+	switch functionCode {
+	case 0x07: // Read Exception Status: status byte
+		return 5, true
+	case 0x05, 0x06, 0x0B, 0x0F, 0x10: // address/value or address/quantity echo
+		return 8, true
+	case 0x16: // Mask Write Register: address, AND mask, OR mask
+		return 10, true
+	case 0x08: // Diagnostics echoes the request
+		return len(request), true
+	case 0x01, 0x02, 0x03, 0x04, 0x0C, 0x11, 0x14, 0x15, 0x17: // one-byte byte count
+		if len(frame) < 3 {
+			return 3, false
+		}
+		return 3 + int(frame[2]) + 2, true
+	case 0x18: // Read FIFO Queue: two-byte byte count
+		if len(frame) < 4 {
+			return 4, false
+		}
+		return 4 + (int(frame[2])<<8 | int(frame[3])) + 2, true
+	case 0x2B:
+		return encapsulatedResponseLength(frame)
+	}
+	return 0, false
+}
+
+// encapsulatedResponseLength walks the object list of a Read Device
+// Identification response (MEI type 0x0E), one object header at a time
+func encapsulatedResponseLength(frame []byte) (int, bool) {
+	if len(frame) < 3 {
+		return 3, false
+	}
+	if frame[2] != 0x0E {
+		return 0, false
+	}
+
+	// MEI type, read device ID code, conformity level, more follows,
+	// next object ID and number of objects
+	const headerEnd = 8
+	if len(frame) < headerEnd {
+		return headerEnd, false
+	}
+
+	offset := headerEnd
+	for i := 0; i < int(frame[headerEnd-1]); i++ {
+		// Object ID and object length
+		if len(frame) < offset+2 {
+			return offset + 2, false
+		}
+		offset += 2 + int(frame[offset+1])
+	}
+	return offset + 2, true
+}
+
+// cancelPollInterval bounds each wait for the first byte of a response while
+// a cancelable context is in use, so cancellation is noticed promptly
+const cancelPollInterval = 10 * time.Millisecond
+
+// readResponse reads one response frame to request. The frame header tells
+// where the frame ends, so the read returns as soon as the last byte arrives;
+// a gap longer than t1.5 after the first byte ends frames of unknown length
+// and reports truncated ones without waiting for the full response timeout.
+// The response timeout is shortened to the deadline of ctx, and canceling
+// ctx abandons the read.
+func (d *ModbusDevice) readResponse(ctx context.Context, request []byte) ([]byte, error) {
+	deadline := time.Now().Add(d.responseTimeout)
+	ctxDeadline, ctxBound := ctx.Deadline()
+	if ctxBound = ctxBound && ctxDeadline.Before(deadline); ctxBound {
+		deadline = ctxDeadline
+	}
+	frame := make([]byte, 0, maxFrameLength)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			d.abortResponse()
+			return nil, contextError(request, err)
+		}
+
+		length, known := responseLength(request, frame)
+		if known && len(frame) >= length {
+			return frame[:length], nil
+		}
+		if length > maxFrameLength {
+			return nil, newError(ModbusInvalidResponse, request, frame,
+				fmt.Errorf("invalid response length: %d bytes exceeds the %d byte frame limit", length, maxFrameLength))
+		}
+
+		// Read exactly what is still missing, or anything up to the frame
+		// limit when the length is not encoded in the frame
+		want := length
+		if want == 0 {
+			want = maxFrameLength
+		}
+		n, err := d.read(ctx, frame[len(frame):want], deadline, len(frame) == 0)
+		if err != nil {
+			return nil, newError(ModbusSerialError, request, frame, fmt.Errorf("failed to read response: %w", err))
+		}
+		frame = frame[:len(frame)+n]
+		if n > 0 {
+			d.lastActivity = time.Now()
+			continue
+		}
+
+		// The read deadline passed without data
+		switch {
+		case len(frame) == 0:
+			if time.Now().After(deadline) {
+				if ctxBound {
+					d.abortResponse()
+					return nil, newError(ModbusTimeoutError, request, nil,
+						fmt.Errorf("%w from slave %d: %w", ErrTimeout, request[0], context.DeadlineExceeded))
+				}
+				return nil, newError(ModbusTimeoutError, request, nil, fmt.Errorf("%w from slave %d", ErrTimeout, request[0]))
+			}
+		case length == 0 && len(frame) >= 4:
+			// Silence ends a frame whose length is not encoded in it
+			return frame, nil
+		default:
+			return nil, newError(ModbusInvalidResponse, request, frame,
+				fmt.Errorf("invalid response length: got %d bytes before the line went silent", len(frame)))
+		}
+	}
+}
+
+// readEcho reads back the request the transceiver looped to the receiver.
+// An echo that differs from the request or breaks off means another station
+// was sending at the same time.
+func (d *ModbusDevice) readEcho(ctx context.Context, request []byte) error {
+	deadline := time.Now().Add(d.responseTimeout)
+	echo := make([]byte, 0, len(request))
+
+	for len(echo) < len(request) {
+		if err := ctx.Err(); err != nil {
+			d.abortResponse()
+			return contextError(request, err)
+		}
+		n, err := d.read(ctx, echo[len(echo):len(request)], deadline, len(echo) == 0)
+		if err != nil {
+			return newError(ModbusSerialError, request, nil, fmt.Errorf("failed to read echo: %w", err))
+		}
+		echo = echo[:len(echo)+n]
+		if n > 0 {
+			continue
+		}
+
+		switch {
+		case len(echo) > 0:
+			d.staleInput = true
+			return newError(ModbusSerialError, request, echo,
+				fmt.Errorf("%w: echo broke off after %d of %d bytes", ErrCollision, len(echo), len(request)))
+		case time.Now().After(deadline):
+			return newError(ModbusSerialError, request, nil, fmt.Errorf("no echo of the request within %v", d.responseTimeout))
+		}
+	}
+
+	if !bytes.Equal(echo, request) {
+		d.staleInput = true
+		return newError(ModbusSerialError, request, echo,
+			fmt.Errorf("%w: sent % X, read back % X", ErrCollision, request, echo))
+	}
+	return nil
+}
+
+// maxEmptyReads is how many reads in a row may return neither data nor an
+// error before the transport is taken to be broken, as bufio does
+const maxEmptyReads = 100
+
+// read reads into p until the deadline setReadDeadline puts on it. Running
+// into the deadline returns 0 and no error; anything else that ends the read
+// without data, end of file included, is an error.
+func (d *ModbusDevice) read(ctx context.Context, p []byte, deadline time.Time, first bool) (int, error) {
+	if err := d.setReadDeadline(ctx, deadline, first); err != nil {
+		return 0, err
+	}
+	for range maxEmptyReads {
+		n, err := d.transport.Read(p)
+		switch {
+		case n > 0:
+			// An error that came with data turns up again on the next read
+			return n, nil
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			return 0, nil
+		case err != nil:
+			return 0, err
+		}
+	}
+	return 0, io.ErrNoProgress
+}
+
+// setReadDeadline bounds the next read: by deadline while waiting for the
+// first byte of a frame, polling a cancelable ctx, and by the inter-character
+// timeout after that
+func (d *ModbusDevice) setReadDeadline(ctx context.Context, deadline time.Time, first bool) error {
+	until := time.Now().Add(d.interCharTimeout)
+	if first {
+		until = deadline
+		if poll := time.Now().Add(cancelPollInterval); ctx.Done() != nil && poll.Before(until) {
+			until = poll
+		}
+	}
+	if err := d.transport.SetReadDeadline(until); err != nil {
+		return fmt.Errorf("failed to set read deadline: %w", err)
+	}
+	return nil
+}
+
+// abortResponse gives up on a response in progress: any part of it already
+// received is discarded, and the rest is flushed before the next request
+func (d *ModbusDevice) abortResponse() {
+	d.enableRX()
+	d.transport.Flush()
+	d.staleInput = true
+	d.lastActivity = time.Now()
+}
@@ -0,0 +1,283 @@
+package modbus
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestResponseLength(t *testing.T) {
+	readRequest := []byte{1, 0x03, 0, 0, 0, 2}
+	tests := []struct {
+		name    string
+		request []byte
+		frame   []byte
+		length  int
+		known   bool
+	}{
+		{"empty", readRequest, nil, 2, false},
+		{"exception", readRequest, []byte{1, 0x83}, exceptionFrameLength, true},
+		{"byte count pending", readRequest, []byte{1, 0x03}, 3, false},
+		{"byte count", readRequest, []byte{1, 0x03, 4}, 9, true},
+		{"write echo", []byte{1, 0x06, 0, 1, 0, 2}, []byte{1, 0x06}, 8, true},
+		{"mask write", []byte{1, 0x16}, []byte{1, 0x16}, 10, true},
+		{"exception status", []byte{1, 0x07}, []byte{1, 0x07}, 5, true},
+		{"diagnostics", []byte{1, 0x08, 0, 0, 1, 2, 3, 4}, []byte{1, 0x08}, 8, true},
+		{"fifo pending", []byte{1, 0x18}, []byte{1, 0x18, 0}, 4, false},
+		{"fifo", []byte{1, 0x18}, []byte{1, 0x18, 0x01, 0x02}, 4 + 0x102 + 2, true},
+		{"unknown function", []byte{1, 0x41}, []byte{1, 0x41}, 0, false},
+		{"device id header", []byte{1, 0x2B}, []byte{1, 0x2B, 0x0E, 1}, 8, false},
+		{"device id object header", []byte{1, 0x2B}, []byte{1, 0x2B, 0x0E, 1, 1, 0, 0, 1}, 10, false},
+		{"device id", []byte{1, 0x2B}, []byte{1, 0x2B, 0x0E, 1, 1, 0, 0, 1, 0, 3}, 15, true},
+		{"other mei type", []byte{1, 0x2B}, []byte{1, 0x2B, 0x0D}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			length, known := responseLength(tt.request, tt.frame)
+			if length != tt.length || known != tt.known {
+				t.Errorf("responseLength(% X) = %d, %v; want %d, %v", tt.frame, length, known, tt.length, tt.known)
+			}
+		})
+	}
+}
+
+func TestReadHoldingRegisters(t *testing.T) {
+	transport := newFakeTransport(holdingRegisters([]uint16{0x1234, 0xABCD, 7}))
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	values, err := d.ReadHoldingRegisters(1, 1, 2)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters: %v", err)
+	}
+	if len(values) != 2 || values[0] != 0xABCD || values[1] != 7 {
+		t.Errorf("ReadHoldingRegisters = %04X, want [ABCD 0007]", values)
+	}
+	want := appendCRC([]byte{1, 0x03, 0, 1, 0, 2})
+	if sent := transport.sent(); len(sent) != 1 || !bytes.Equal(sent[0], want) {
+		t.Errorf("sent % X, want % X", sent, want)
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(nil))
+	defer d.Close()
+
+	start := time.Now()
+	_, err := d.ReadHoldingRegisters(1, 0, 1)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("ReadHoldingRegisters error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("timed out after %v, want 100ms", elapsed)
+	}
+}
+
+func TestResponseTimeoutBlockingTransport(t *testing.T) {
+	conn, slave := net.Pipe()
+	defer slave.Close()
+	go io.Copy(io.Discard, slave)
+
+	d := newTestDevice(t, pipeTransport{conn})
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.ReadHoldingRegisters(1, 0, 1)
+		d.Close()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTimeout) {
+			t.Errorf("ReadHoldingRegisters error = %v, want ErrTimeout", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ReadHoldingRegisters or Close still blocked after 2s")
+	}
+}
+
+// silentTransport returns neither data nor an error from Read
+type silentTransport struct {
+	*fakeTransport
+	reads int
+}
+
+func (t *silentTransport) Read(p []byte) (int, error) {
+	t.reads++
+	return 0, nil
+}
+
+func TestEmptyReadsFail(t *testing.T) {
+	transport := &silentTransport{fakeTransport: newFakeTransport(nil)}
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	_, err := d.ReadHoldingRegisters(1, 0, 1)
+	if !errors.Is(err, io.ErrNoProgress) || ErrorCode(err) != ModbusSerialError {
+		t.Errorf("ReadHoldingRegisters error = %v, want a serial error wrapping io.ErrNoProgress", err)
+	}
+	if transport.reads != maxEmptyReads {
+		t.Errorf("made %d reads, want %d", transport.reads, maxEmptyReads)
+	}
+}
+
+func TestTruncatedResponse(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(func([]byte) []byte {
+		return []byte{1, 0x03, 4, 0x12}
+	}))
+	defer d.Close()
+
+	_, err := d.ReadHoldingRegisters(1, 0, 2)
+	var e *Error
+	if !errors.As(err, &e) || e.Code != ModbusInvalidResponse {
+		t.Fatalf("ReadHoldingRegisters error = %v, want ModbusInvalidResponse", err)
+	}
+	if !bytes.Equal(e.Frame, []byte{1, 0x03, 4, 0x12}) {
+		t.Errorf("Frame = % X, want the bytes received", e.Frame)
+	}
+}
+
+func TestCRCError(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(func([]byte) []byte {
+		frame := appendCRC([]byte{1, 0x03, 2, 0, 5})
+		frame[len(frame)-1] ^= 0xFF
+		return frame
+	}))
+	defer d.Close()
+
+	_, err := d.ReadHoldingRegisters(1, 0, 1)
+	if !errors.Is(err, ErrCRC) || ErrorCode(err) != ModbusCRCError {
+		t.Errorf("ReadHoldingRegisters error = %v, want ErrCRC", err)
+	}
+}
+
+func TestExceptionResponse(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(respond(1, 0x83, 0x02)))
+	defer d.Close()
+
+	_, err := d.ReadHoldingRegisters(1, 0, 1)
+	var exc *ExceptionError
+	if !errors.As(err, &exc) {
+		t.Fatalf("ReadHoldingRegisters error = %v, want *ExceptionError", err)
+	}
+	if exc.SlaveID != 1 || exc.FunctionCode != 0x03 || exc.Code != ExceptionIllegalDataAddress {
+		t.Errorf("exception = %+v, want slave 1, function 0x03, Illegal Data Address", exc)
+	}
+	if !errors.Is(err, ModbusExceptionResponse) || ErrorCode(err) != ModbusExceptionResponse {
+		t.Errorf("%v is not classed as ModbusExceptionResponse", err)
+	}
+	if got := exc.Code.String(); got != "Illegal Data Address" {
+		t.Errorf("Code.String() = %q", got)
+	}
+	if got := ExceptionCode(0x42).String(); got != "Unknown Exception 0x42" {
+		t.Errorf("ExceptionCode(0x42).String() = %q", got)
+	}
+}
+
+func TestSlaveIDMismatch(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(respond(2, 0x03, 2, 0, 5)))
+	defer d.Close()
+
+	_, err := d.ReadHoldingRegisters(1, 0, 1)
+	if ErrorCode(err) != ModbusInvalidResponse {
+		t.Errorf("ReadHoldingRegisters error = %v, want ModbusInvalidResponse", err)
+	}
+}
+
+// echoing builds a slave behind a transceiver that loops the request back to
+// the receiver ahead of the response of slave
+func echoing(slave func([]byte) []byte) func([]byte) []byte {
+	return func(request []byte) []byte {
+		return append(append([]byte(nil), request...), slave(request)...)
+	}
+}
+
+// withLocalEcho reads back every request, as newTestDevice times out
+func withLocalEcho() DeviceOption {
+	return WithSerialOptions(SerialOptions{ResponseTimeout: 100 * time.Millisecond, LocalEcho: true})
+}
+
+func TestLocalEcho(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(echoing(holdingRegisters([]uint16{0x1234, 0x5678}))), withLocalEcho())
+	defer d.Close()
+
+	// The echo is read and dropped, so the response parses as without it
+	values, err := d.ReadHoldingRegisters(1, 0, 2)
+	if err != nil || len(values) != 2 || values[0] != 0x1234 || values[1] != 0x5678 {
+		t.Errorf("ReadHoldingRegisters = %04X, %v; want [1234 5678]", values, err)
+	}
+	// A write's echo and its response are the same frame
+	if err := d.WriteRegister(1, 0, 0x1234); err != nil {
+		t.Errorf("WriteRegister: %v", err)
+	}
+}
+
+func TestLocalEchoMismatch(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(echoing(respond(1, 0x06, 0x00, 0x10, 0x00, 0x00))), withLocalEcho())
+	defer d.Close()
+
+	// The echo matches, the response after it does not
+	var mismatch *MismatchError
+	if err := d.WriteRegister(1, 0x10, 0x1234); !errors.As(err, &mismatch) {
+		t.Fatalf("WriteRegister error = %v, want a *MismatchError", err)
+	}
+	if want := appendCRC([]byte{1, 0x06, 0x00, 0x10, 0x00, 0x00}); !bytes.Equal(mismatch.Response, want) {
+		t.Errorf("MismatchError.Response = % X, want the response % X, not the echo", mismatch.Response, want)
+	}
+}
+
+func TestLocalEchoCollision(t *testing.T) {
+	for name, echo := range map[string]func([]byte) []byte{
+		"corrupted": func(request []byte) []byte {
+			echo := append([]byte(nil), request...)
+			echo[3] ^= 0x01
+			return echo
+		},
+		"broken off": func(request []byte) []byte { return request[:3] },
+	} {
+		// Another station garbles the first request only
+		collisions := 1
+		slave := echoing(holdingRegisters([]uint16{42}))
+		transport := newFakeTransport(func(request []byte) []byte {
+			if collisions > 0 {
+				collisions--
+				return echo(request)
+			}
+			return slave(request)
+		})
+		d := newTestDevice(t, transport, withLocalEcho())
+		defer d.Close()
+
+		if _, err := d.ReadHoldingRegisters(1, 0, 1); !errors.Is(err, ErrCollision) || ErrorCode(err) != ModbusSerialError {
+			t.Errorf("%s echo: ReadHoldingRegisters error = %v, want ErrCollision", name, err)
+		}
+		// The next request starts afresh
+		if values, err := d.ReadHoldingRegisters(1, 0, 1); err != nil || values[0] != 42 {
+			t.Errorf("%s echo: ReadHoldingRegisters after the collision = %v, %v; want [42]", name, values, err)
+		}
+	}
+}
+
+func TestLocalEchoCollisionRetried(t *testing.T) {
+	collisions := 1
+	slave := echoing(holdingRegisters([]uint16{42}))
+	transport := newFakeTransport(func(request []byte) []byte {
+		if collisions > 0 {
+			collisions--
+			return append(append([]byte(nil), request[:4]...), 0xFF, 0xFF, 0xFF, 0xFF)
+		}
+		return slave(request)
+	})
+	d := newTestDevice(t, transport, withLocalEcho(), WithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+	defer d.Close()
+
+	if values, err := d.ReadHoldingRegisters(1, 0, 1); err != nil || values[0] != 42 {
+		t.Errorf("ReadHoldingRegisters = %v, %v; want [42]", values, err)
+	}
+	if n := len(transport.sent()); n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+}
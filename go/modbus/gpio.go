@@ -0,0 +1,199 @@
+package modbus
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/stianeikeland/go-rpio/v4"
+)
+
+// GPIOLine is a single output line, such as the DE or RE input of a
+// transceiver
+type GPIOLine interface {
+	Set(high bool) error
+	Close() error
+}
+
+// GPIOChip hands out output lines by offset and looks lines up by name
+type GPIOChip interface {
+	RequestOutput(offset int, consumer string, high bool) (GPIOLine, error)
+	FindLine(name string) (int, error)
+	Close() error
+}
+
+// gpioConsumer labels the lines this driver requests
+const gpioConsumer = "modbus-rtu"
+
+// gpioDirection drives DE and /RE lines, or a single line when they are
+// tied together
+type gpioDirection struct {
+	chip     GPIOChip
+	de       GPIOLine
+	re       GPIOLine
+	invertDE bool
+	invertRE bool
+}
+
+// NewGPIODirection maps the BCM GPIO registers through go-rpio and
+// configures dePin and rePin as outputs, starting in receive mode
+func NewGPIODirection(dePin, rePin int, cfg DirectionConfig) (DirectionController, error) {
+	chip, err := openRPIOChip()
+	if err != nil {
+		return nil, err
+	}
+	return NewChipDirection(chip, strconv.Itoa(dePin), strconv.Itoa(rePin), cfg)
+}
+
+// NewGPIOChipDirection opens a GPIO character device such as
+// /dev/gpiochip0 and drives DE and RE through it. Lines are given as
+// offsets on the chip ("17") or as line names ("GPIO17").
+func NewGPIOChipDirection(chipPath string, deLine, reLine string, cfg DirectionConfig) (DirectionController, error) {
+	chip, err := OpenGPIOChip(chipPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewChipDirection(chip, deLine, reLine, cfg)
+}
+
+// NewChipDirection drives DE and RE through lines of chip, which is closed
+// together with the controller. In DirectionTied mode reLine is unused.
+func NewChipDirection(chip GPIOChip, deLine, reLine string, cfg DirectionConfig) (DirectionController, error) {
+	if cfg.Mode != DirectionSplit && cfg.Mode != DirectionTied {
+		chip.Close()
+		return nil, fmt.Errorf("direction mode %s does not use GPIO lines", cfg.Mode)
+	}
+
+	g := &gpioDirection{
+		chip:     chip,
+		invertDE: cfg.InvertDE,
+		invertRE: cfg.InvertRE,
+	}
+
+	var err error
+	// Request the lines at their receive levels
+	g.de, err = requestLine(chip, deLine, g.invertDE)
+	if err != nil {
+		chip.Close()
+		return nil, fmt.Errorf("failed to request DE line: %v", err)
+	}
+	if cfg.Mode == DirectionSplit {
+		g.re, err = requestLine(chip, reLine, g.invertRE)
+		if err != nil {
+			g.de.Close()
+			chip.Close()
+			return nil, fmt.Errorf("failed to request RE line: %v", err)
+		}
+	}
+
+	return withSetupHold(g, cfg), nil
+}
+
+// requestLine requests the line given by offset or name as an output at the
+// given initial level
+func requestLine(chip GPIOChip, line string, high bool) (GPIOLine, error) {
+	offset, err := strconv.Atoi(line)
+	if err != nil {
+		offset, err = chip.FindLine(line)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chip.RequestOutput(offset, gpioConsumer, high)
+}
+
+// EnableTX enables RS485 transmit mode
+func (g *gpioDirection) EnableTX() error {
+	// For ISL43485IBZ:
+	// DE must be HIGH to enable transmission
+	// RE must be HIGH to disable reception
+	if g.re != nil {
+		if err := g.re.Set(!g.invertRE); err != nil {
+			return err
+		}
+		time.Sleep(gpioSwitchDelay)
+	}
+	if err := g.de.Set(!g.invertDE); err != nil {
+		return err
+	}
+	time.Sleep(gpioSwitchDelay)
+	return nil
+}
+
+// EnableRX enables RS485 receive mode
+func (g *gpioDirection) EnableRX() error {
+	// For ISL43485IBZ:
+	// DE must be LOW to disable transmission
+	// RE must be LOW to enable reception
+	if err := g.de.Set(g.invertDE); err != nil {
+		return err
+	}
+	time.Sleep(gpioSwitchDelay)
+	if g.re != nil {
+		if err := g.re.Set(g.invertRE); err != nil {
+			return err
+		}
+		time.Sleep(gpioSwitchDelay)
+	}
+	return nil
+}
+
+// Close releases the lines and the chip
+func (g *gpioDirection) Close() error {
+	g.de.Close()
+	if g.re != nil {
+		g.re.Close()
+	}
+	return g.chip.Close()
+}
+
+// rpioChip exposes the BCM283x GPIO block mapped by go-rpio as a GPIOChip.
+// go-rpio state is process-global, so only one may be open at a time.
+type rpioChip struct{}
+
+func openRPIOChip() (*rpioChip, error) {
+	if err := rpio.Open(); err != nil {
+		return nil, fmt.Errorf("failed to initialize GPIO: %v", err)
+	}
+	return &rpioChip{}, nil
+}
+
+func (rpioChip) RequestOutput(offset int, consumer string, high bool) (GPIOLine, error) {
+	line := rpioLine{pin: rpio.Pin(offset)}
+	line.Set(high)
+	line.pin.Output()
+	return line, nil
+}
+
+func (rpioChip) FindLine(name string) (int, error) {
+	return 0, fmt.Errorf("line %q: go-rpio addresses lines by BCM number only", name)
+}
+
+func (rpioChip) Close() error {
+	return rpio.Close()
+}
+
+// rpioLine is a BCM GPIO pin driven through go-rpio
+type rpioLine struct {
+	pin rpio.Pin
+}
+
+func (l rpioLine) Set(high bool) error {
+	if high {
+		l.pin.High()
+	} else {
+		l.pin.Low()
+	}
+	return nil
+}
+
+func (l rpioLine) Close() error {
+	return nil
+}
+
+// noDirection is used for transceivers that switch direction on their own
+type noDirection struct{}
+
+func (noDirection) EnableTX() error { return nil }
+func (noDirection) EnableRX() error { return nil }
+func (noDirection) Close() error    { return nil }
@@ -0,0 +1,206 @@
+package modbus
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// fakeGPIOChip is an in-memory GPIOChip that records every value driven
+// onto its lines
+type fakeGPIOChip struct {
+	mu        sync.Mutex
+	names     []string
+	values    map[int]bool
+	requested map[int]bool
+	history   []gpioEvent
+	closed    bool
+}
+
+// gpioEvent is one value driven onto a fakeGPIOChip line
+type gpioEvent struct {
+	offset int
+	high   bool
+}
+
+// newFakeGPIOChip creates a fake chip with one line per name
+func newFakeGPIOChip(names ...string) *fakeGPIOChip {
+	return &fakeGPIOChip{
+		names:     names,
+		values:    make(map[int]bool),
+		requested: make(map[int]bool),
+	}
+}
+
+func (c *fakeGPIOChip) RequestOutput(offset int, consumer string, high bool) (GPIOLine, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if offset < 0 || offset >= len(c.names) {
+		return nil, fmt.Errorf("line %d out of range", offset)
+	}
+	if c.requested[offset] {
+		return nil, fmt.Errorf("line %d busy", offset)
+	}
+	c.requested[offset] = true
+	c.values[offset] = high
+	return &fakeGPIOLine{chip: c, offset: offset}, nil
+}
+
+func (c *fakeGPIOChip) FindLine(name string) (int, error) {
+	if offset := slices.Index(c.names, name); offset >= 0 {
+		return offset, nil
+	}
+	return 0, fmt.Errorf("no line named %q", name)
+}
+
+func (c *fakeGPIOChip) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// levels returns the current level of each line
+func (c *fakeGPIOChip) levels(offsets ...int) []bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	levels := make([]bool, len(offsets))
+	for i, offset := range offsets {
+		levels[i] = c.values[offset]
+	}
+	return levels
+}
+
+// takeHistory returns the values driven since the last call
+func (c *fakeGPIOChip) takeHistory() []gpioEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	history := c.history
+	c.history = nil
+	return history
+}
+
+type fakeGPIOLine struct {
+	chip   *fakeGPIOChip
+	offset int
+}
+
+func (l *fakeGPIOLine) Set(high bool) error {
+	l.chip.mu.Lock()
+	defer l.chip.mu.Unlock()
+	if !l.chip.requested[l.offset] {
+		return fmt.Errorf("line %d released", l.offset)
+	}
+	l.chip.values[l.offset] = high
+	l.chip.history = append(l.chip.history, gpioEvent{offset: l.offset, high: high})
+	return nil
+}
+
+func (l *fakeGPIOLine) Close() error {
+	l.chip.mu.Lock()
+	defer l.chip.mu.Unlock()
+	delete(l.chip.requested, l.offset)
+	return nil
+}
+
+// Line offsets on the fake chip used below
+const (
+	testDE = 1
+	testRE = 2
+)
+
+func TestChipDirection(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  DirectionConfig
+		// rx and tx are the DE and RE levels while receiving and sending
+		rx, tx [2]bool
+		// txOrder and rxOrder are the lines in the order they switch
+		txOrder, rxOrder []int
+	}{
+		{
+			name: "split",
+			cfg:  DirectionConfig{Mode: DirectionSplit},
+			rx:   [2]bool{false, false}, tx: [2]bool{true, true},
+			txOrder: []int{testRE, testDE}, rxOrder: []int{testDE, testRE},
+		},
+		{
+			name: "split inverted",
+			cfg:  DirectionConfig{Mode: DirectionSplit, InvertDE: true, InvertRE: true},
+			rx:   [2]bool{true, true}, tx: [2]bool{false, false},
+			txOrder: []int{testRE, testDE}, rxOrder: []int{testDE, testRE},
+		},
+		{
+			name: "tied",
+			cfg:  DirectionConfig{Mode: DirectionTied},
+			rx:   [2]bool{false, false}, tx: [2]bool{true, false},
+			txOrder: []int{testDE}, rxOrder: []int{testDE},
+		},
+		{
+			name: "tied inverted",
+			cfg:  DirectionConfig{Mode: DirectionTied, InvertDE: true},
+			rx:   [2]bool{true, false}, tx: [2]bool{false, false},
+			txOrder: []int{testDE}, rxOrder: []int{testDE},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chip := newFakeGPIOChip("", "DE", "RE")
+			dc, err := NewChipDirection(chip, "DE", "2", tt.cfg)
+			if err != nil {
+				t.Fatalf("NewChipDirection: %v", err)
+			}
+
+			check := func(state string, levels [2]bool, order []int) {
+				t.Helper()
+				if got := chip.levels(testDE, testRE); got[0] != levels[0] || got[1] != levels[1] {
+					t.Errorf("%s: DE, RE = %v, want %v", state, got, levels)
+				}
+				var lines []int
+				for _, e := range chip.takeHistory() {
+					lines = append(lines, e.offset)
+				}
+				if !slices.Equal(lines, order) {
+					t.Errorf("%s: lines switched in order %v, want %v", state, lines, order)
+				}
+			}
+
+			// The lines are requested at their receive levels
+			check("initially", tt.rx, nil)
+			if err := dc.EnableTX(); err != nil {
+				t.Fatal(err)
+			}
+			check("sending", tt.tx, tt.txOrder)
+			if err := dc.EnableRX(); err != nil {
+				t.Fatal(err)
+			}
+			check("receiving", tt.rx, tt.rxOrder)
+
+			if err := dc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !chip.closed || len(chip.requested) != 0 {
+				t.Error("Close left the chip open or lines requested")
+			}
+		})
+	}
+}
+
+func TestChipDirectionErrors(t *testing.T) {
+	chip := newFakeGPIOChip("DE")
+	if _, err := NewChipDirection(chip, "DE", "RE", DirectionConfig{Mode: DirectionSplit}); err == nil {
+		t.Error("NewChipDirection accepted a missing RE line")
+	}
+	if !chip.closed || len(chip.requested) != 0 {
+		t.Error("a failed NewChipDirection left the chip open or lines requested")
+	}
+
+	chip = newFakeGPIOChip("DE")
+	if _, err := NewChipDirection(chip, "DE", "", DirectionConfig{Mode: DirectionRTS}); err == nil {
+		t.Error("NewChipDirection accepted RTS mode")
+	}
+	if !chip.closed {
+		t.Error("a failed NewChipDirection left the chip open")
+	}
+}
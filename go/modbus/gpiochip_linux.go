@@ -0,0 +1,168 @@
+//go:build linux
+
+package modbus
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Structures of the GPIO v2 character device uAPI from <linux/gpio.h>
+
+type gpiochipInfo struct {
+	Name  [32]byte
+	Label [32]byte
+	Lines uint32
+}
+
+type gpioV2LineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	Attr gpioV2LineAttribute
+	Mask uint64
+}
+
+type gpioV2LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [10]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	Offsets         [64]uint32
+	Consumer        [32]byte
+	Config          gpioV2LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	FD              int32
+}
+
+type gpioV2LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+type gpioV2LineInfo struct {
+	Name     [32]byte
+	Consumer [32]byte
+	Offset   uint32
+	NumAttrs uint32
+	Flags    uint64
+	Attrs    [10]gpioV2LineAttribute
+	Padding  [4]uint32
+}
+
+const (
+	gpioV2LineFlagOutput        = 1 << 3
+	gpioV2LineAttrIDOutputValue = 2
+)
+
+// gpioIoctl builds a GPIO ioctl request number like the _IOR/_IOWR macros
+func gpioIoctl(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 0xB4<<8 | nr
+}
+
+const (
+	iocRead      = 2
+	iocReadWrite = 3
+)
+
+var (
+	gpioGetChipInfoIoctl     = gpioIoctl(iocRead, 0x01, unsafe.Sizeof(gpiochipInfo{}))
+	gpioV2GetLineInfoIoctl   = gpioIoctl(iocReadWrite, 0x05, unsafe.Sizeof(gpioV2LineInfo{}))
+	gpioV2GetLineIoctl       = gpioIoctl(iocReadWrite, 0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineSetValuesIoctl = gpioIoctl(iocReadWrite, 0x0F, unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+func ioctlPtr(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// cdevChip is a GPIO chip opened through its character device
+type cdevChip struct {
+	fd   int
+	path string
+}
+
+// OpenGPIOChip opens a GPIO character device such as /dev/gpiochip0
+func OpenGPIOChip(path string) (GPIOChip, error) {
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GPIO chip %s: %v", path, err)
+	}
+	return &cdevChip{fd: fd, path: path}, nil
+}
+
+// RequestOutput requests one line as an output at the given level
+func (c *cdevChip) RequestOutput(offset int, consumer string, high bool) (GPIOLine, error) {
+	var req gpioV2LineRequest
+	req.Offsets[0] = uint32(offset)
+	req.NumLines = 1
+	copy(req.Consumer[:len(req.Consumer)-1], consumer)
+	req.Config.Flags = gpioV2LineFlagOutput
+	req.Config.NumAttrs = 1
+	req.Config.Attrs[0].Attr.ID = gpioV2LineAttrIDOutputValue
+	if high {
+		req.Config.Attrs[0].Attr.Value = 1
+	}
+	req.Config.Attrs[0].Mask = 1
+
+	if err := ioctlPtr(c.fd, gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("failed to request line %d of %s: %v", offset, c.path, err)
+	}
+	return &cdevLine{fd: int(req.FD)}, nil
+}
+
+// FindLine returns the offset of the line called name
+func (c *cdevChip) FindLine(name string) (int, error) {
+	var info gpiochipInfo
+	if err := ioctlPtr(c.fd, gpioGetChipInfoIoctl, unsafe.Pointer(&info)); err != nil {
+		return 0, fmt.Errorf("failed to read %s info: %v", c.path, err)
+	}
+
+	for offset := uint32(0); offset < info.Lines; offset++ {
+		line := gpioV2LineInfo{Offset: offset}
+		if err := ioctlPtr(c.fd, gpioV2GetLineInfoIoctl, unsafe.Pointer(&line)); err != nil {
+			return 0, fmt.Errorf("failed to read line %d of %s: %v", offset, c.path, err)
+		}
+		if string(bytes.TrimRight(line.Name[:], "\x00")) == name {
+			return int(offset), nil
+		}
+	}
+	return 0, fmt.Errorf("no line named %q on %s", name, c.path)
+}
+
+// Close closes the chip. Requested lines stay valid until closed.
+func (c *cdevChip) Close() error {
+	return unix.Close(c.fd)
+}
+
+// cdevLine is a line request file descriptor holding a single line
+type cdevLine struct {
+	fd int
+}
+
+func (l *cdevLine) Set(high bool) error {
+	values := gpioV2LineValues{Mask: 1}
+	if high {
+		values.Bits = 1
+	}
+	return ioctlPtr(l.fd, gpioV2LineSetValuesIoctl, unsafe.Pointer(&values))
+}
+
+func (l *cdevLine) Close() error {
+	return unix.Close(l.fd)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package modbus
+
+import "errors"
+
+// OpenGPIOChip is only implemented on Linux
+func OpenGPIOChip(path string) (GPIOChip, error) {
+	return nil, errors.New("GPIO character devices are only supported on Linux")
+}
@@ -0,0 +1,59 @@
+package modbus
+
+import "fmt"
+
+// Limits the Modbus application protocol sets on a single request
+const (
+	// MaxSlaveID is the highest slave address; 248 to 255 are reserved
+	MaxSlaveID = 247
+
+	// MaxReadBits is the most coils or discrete inputs one read returns
+	MaxReadBits = 2000
+
+	// MaxReadRegisters is the most registers one read returns
+	MaxReadRegisters = 125
+
+	// MaxWriteCoils is the most coils one Write Multiple Coils sets
+	MaxWriteCoils = 1968
+
+	// MaxWriteRegisters is the most registers one Write Multiple
+	// Registers sets
+	MaxWriteRegisters = 123
+
+	// MaxReadWriteRegisters is the most registers one Read/Write Multiple
+	// Registers sets; it reads up to MaxReadRegisters
+	MaxReadWriteRegisters = 121
+
+	// MaxDiagnosticData is the most data one Diagnostics request carries:
+	// the frame limit less slave ID, function code, sub-function and CRC
+	MaxDiagnosticData = maxFrameLength - 6
+
+	// MaxFileRecordNumber is the last record of a file, which holds 10000
+	// records of one register each
+	MaxFileRecordNumber = 9999
+
+	// MaxFileReadRegisters is the most records one Read File Record
+	// returns, and MaxFileWriteRegisters the most one Write File Record
+	// sets, when the request holds a single sub-request
+	MaxFileReadRegisters  = (maxFileReadBytes - 2) / 2
+	MaxFileWriteRegisters = (maxFileWriteBytes - 7) / 2
+)
+
+// addressSpace is the number of addresses in each data table
+const addressSpace = 1 << 16
+
+// checkRange rejects a request for count items from startAddr that exceeds
+// the quantity limit of its function or runs past the last address
+func checkRange(slaveID, functionCode byte, startAddr uint16, count, limit int) error {
+	var err error
+	switch {
+	case count < 1 || count > limit:
+		err = fmt.Errorf("invalid quantity %d: function 0x%02X takes 1 to %d", count, functionCode, limit)
+	case int(startAddr)+count > addressSpace:
+		err = fmt.Errorf("invalid address range: %d items from address %d run past address %d", count, startAddr, addressSpace-1)
+	}
+	if err != nil {
+		return newError(ModbusInvalidRequest, []byte{slaveID, functionCode}, nil, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package modbus
+
+import "testing"
+
+func TestCheckRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		startAddr uint16
+		count     int
+		ok        bool
+	}{
+		{"single", 0, 1, true},
+		{"at the limit", 0, MaxReadRegisters, true},
+		{"up to the last address", 0xFFFF - MaxReadRegisters + 1, MaxReadRegisters, true},
+		{"zero", 0, 0, false},
+		{"negative", 0, -1, false},
+		{"over the limit", 0, MaxReadRegisters + 1, false},
+		{"past the last address", 0xFFFF, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRange(1, 0x03, tt.startAddr, tt.count, MaxReadRegisters)
+			if tt.ok && err != nil {
+				t.Errorf("checkRange(%d, %d) = %v, want nil", tt.startAddr, tt.count, err)
+			}
+			if !tt.ok && ErrorCode(err) != ModbusInvalidRequest {
+				t.Errorf("checkRange(%d, %d) = %v, want ModbusInvalidRequest", tt.startAddr, tt.count, err)
+			}
+		})
+	}
+}
+
+func TestOutOfRangeRequestsStayOffTheBus(t *testing.T) {
+	transport := newFakeTransport(nil)
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	if _, err := d.ReadHoldingRegisters(1, 0, MaxReadRegisters+1); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("ReadHoldingRegisters error = %v, want ModbusInvalidRequest", err)
+	}
+	if err := d.WriteMultipleCoils(1, 0xFFFF, []bool{true, false}); ErrorCode(err) != ModbusInvalidRequest {
+		t.Errorf("WriteMultipleCoils error = %v, want ModbusInvalidRequest", err)
+	}
+	if len(transport.sent()) != 0 {
+		t.Error("an out of range request reached the bus")
+	}
+}
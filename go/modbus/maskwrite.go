@@ -0,0 +1,143 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// WithMaskWriteFallback makes MaskWriteRegister and the bit helpers fall
+// back to reading the register and writing it back whole for slaves that
+// answer function 0x16 with Illegal Function. Such slaves are remembered, so
+// later calls go straight to the fallback. Unlike a mask write, the fallback
+// is not atomic: a change the slave makes to the register between the read
+// and the write is lost.
+func WithMaskWriteFallback() DeviceOption {
+	return func(c *deviceConfig) {
+		c.maskWriteFallback = true
+	}
+}
+
+// maskedValue is the register value a mask write of andMask and orMask
+// leaves behind
+func maskedValue(current, andMask, orMask uint16) uint16 {
+	return current&andMask | orMask&^andMask
+}
+
+// MaskWriteRegister changes single bits of a holding register (function
+// 0x16): the slave stores (current AND andMask) OR (orMask AND NOT andMask),
+// so bits cleared in andMask take their value from orMask and the others
+// are kept.
+func (d *ModbusDevice) MaskWriteRegister(slaveID byte, regAddr uint16, andMask, orMask uint16) error {
+	return d.MaskWriteRegisterContext(context.Background(), slaveID, regAddr, andMask, orMask)
+}
+
+// MaskWriteRegisterContext is MaskWriteRegister with a context bounding the
+// wait for the bus and for the response
+func (d *ModbusDevice) MaskWriteRegisterContext(ctx context.Context, slaveID byte, regAddr uint16, andMask, orMask uint16) error {
+	if _, unsupported := d.noMaskWrite.Load(slaveID); unsupported {
+		return d.readModifyWrite(ctx, slaveID, regAddr, andMask, orMask)
+	}
+
+	request := []byte{
+		slaveID,
+		0x16,
+		byte(regAddr >> 8),
+		byte(regAddr & 0xFF),
+		byte(andMask >> 8),
+		byte(andMask & 0xFF),
+		byte(orMask >> 8),
+		byte(orMask & 0xFF),
+	}
+
+	response, err := d.sendModbusRequest(ctx, request)
+	var exc *ExceptionError
+	if d.maskWriteFallback && errors.As(err, &exc) && exc.Code == ExceptionIllegalFunction {
+		d.noMaskWrite.Store(slaveID, true)
+		return d.readModifyWrite(ctx, slaveID, regAddr, andMask, orMask)
+	}
+	if err != nil {
+		return err
+	}
+	return checkEcho(request, response, 8)
+}
+
+// readModifyWrite applies a mask write with functions 0x03 and 0x06, see
+// WithMaskWriteFallback
+func (d *ModbusDevice) readModifyWrite(ctx context.Context, slaveID byte, regAddr uint16, andMask, orMask uint16) error {
+	values, err := d.ReadHoldingRegistersContext(ctx, slaveID, regAddr, 1)
+	if err != nil {
+		return err
+	}
+	return d.WriteRegisterContext(ctx, slaveID, regAddr, maskedValue(values[0], andMask, orMask))
+}
+
+// bitMask returns the mask selecting bit of a register, bit 0 being the
+// least significant
+func bitMask(slaveID byte, bit int) (uint16, error) {
+	if bit < 0 || bit > 15 {
+		return 0, newError(ModbusInvalidRequest, []byte{slaveID, 0x16}, nil,
+			fmt.Errorf("invalid bit %d: registers have bits 0 to 15", bit))
+	}
+	return 1 << bit, nil
+}
+
+// SetBit sets one bit of a holding register, bit 0 being the least
+// significant, without touching the others
+func (d *ModbusDevice) SetBit(slaveID byte, regAddr uint16, bit int) error {
+	return d.SetBitContext(context.Background(), slaveID, regAddr, bit)
+}
+
+// SetBitContext is SetBit with a context bounding the wait for the bus and
+// for the response
+func (d *ModbusDevice) SetBitContext(ctx context.Context, slaveID byte, regAddr uint16, bit int) error {
+	mask, err := bitMask(slaveID, bit)
+	if err != nil {
+		return err
+	}
+	return d.MaskWriteRegisterContext(ctx, slaveID, regAddr, ^mask, mask)
+}
+
+// ClearBit clears one bit of a holding register, bit 0 being the least
+// significant, without touching the others
+func (d *ModbusDevice) ClearBit(slaveID byte, regAddr uint16, bit int) error {
+	return d.ClearBitContext(context.Background(), slaveID, regAddr, bit)
+}
+
+// ClearBitContext is ClearBit with a context bounding the wait for the bus
+// and for the response
+func (d *ModbusDevice) ClearBitContext(ctx context.Context, slaveID byte, regAddr uint16, bit int) error {
+	mask, err := bitMask(slaveID, bit)
+	if err != nil {
+		return err
+	}
+	return d.MaskWriteRegisterContext(ctx, slaveID, regAddr, ^mask, 0)
+}
+
+// ToggleBit inverts one bit of a holding register, bit 0 being the least
+// significant, and returns its new value. The register is read first and the
+// bit then set or cleared with a mask write, so the other bits are kept even
+// if they change in between.
+func (d *ModbusDevice) ToggleBit(slaveID byte, regAddr uint16, bit int) (bool, error) {
+	return d.ToggleBitContext(context.Background(), slaveID, regAddr, bit)
+}
+
+// ToggleBitContext is ToggleBit with a context bounding the wait for the bus
+// and for the responses
+func (d *ModbusDevice) ToggleBitContext(ctx context.Context, slaveID byte, regAddr uint16, bit int) (bool, error) {
+	mask, err := bitMask(slaveID, bit)
+	if err != nil {
+		return false, err
+	}
+	values, err := d.ReadHoldingRegistersContext(ctx, slaveID, regAddr, 1)
+	if err != nil {
+		return false, err
+	}
+
+	set := values[0]&mask == 0
+	orMask := uint16(0)
+	if set {
+		orMask = mask
+	}
+	return set, d.MaskWriteRegisterContext(ctx, slaveID, regAddr, ^mask, orMask)
+}
@@ -0,0 +1,128 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// registerSlave builds a slave that keeps regs, serving Read Holding
+// Registers and Write Single Register and, if maskWrite is set, Mask Write
+// Register. Other functions get an Illegal Function exception.
+func registerSlave(regs []uint16, maskWrite bool) func([]byte) []byte {
+	return func(request []byte) []byte {
+		addr := binary.BigEndian.Uint16(request[2:])
+		switch {
+		case request[1] == 0x03:
+			return holdingRegisters(regs)(request)
+		case request[1] == 0x06:
+			regs[addr] = binary.BigEndian.Uint16(request[4:])
+			return request
+		case request[1] == 0x16 && maskWrite:
+			regs[addr] = maskedValue(regs[addr], binary.BigEndian.Uint16(request[4:]), binary.BigEndian.Uint16(request[6:]))
+			return request
+		}
+		return appendCRC([]byte{request[0], request[1] | 0x80, byte(ExceptionIllegalFunction)})
+	}
+}
+
+// functionCodes returns the function code of each request sent
+func functionCodes(requests [][]byte) []byte {
+	var codes []byte
+	for _, r := range requests {
+		codes = append(codes, r[1])
+	}
+	return codes
+}
+
+func TestMaskedValue(t *testing.T) {
+	for _, tc := range []struct{ current, andMask, orMask, want uint16 }{
+		// The example of the specification
+		{0x0012, 0x00F2, 0x0025, 0x0017},
+		{0xFFFF, 0xFFFF, 0x0000, 0xFFFF},
+		{0x1234, 0x0000, 0xABCD, 0xABCD},
+		{0x0000, 0xFFFE, 0x0001, 0x0001},
+		{0xFFFF, 0xFFFE, 0x0000, 0xFFFE},
+		// Bits set in andMask are kept whatever orMask says
+		{0x00F0, 0x00FF, 0xFFFF, 0xFFF0},
+	} {
+		if got := maskedValue(tc.current, tc.andMask, tc.orMask); got != tc.want {
+			t.Errorf("maskedValue(%04X, %04X, %04X) = %04X, want %04X", tc.current, tc.andMask, tc.orMask, got, tc.want)
+		}
+	}
+}
+
+func TestMaskWriteRegister(t *testing.T) {
+	regs := []uint16{0, 0x0012}
+	transport := newFakeTransport(registerSlave(regs, true))
+	d := newTestDevice(t, transport, WithMaskWriteFallback())
+	defer d.Close()
+
+	if err := d.MaskWriteRegister(1, 1, 0x00F2, 0x0025); err != nil {
+		t.Fatalf("MaskWriteRegister: %v", err)
+	}
+	if regs[1] != 0x0017 {
+		t.Errorf("register = %04X, want 0017", regs[1])
+	}
+	if codes := functionCodes(transport.sent()); string(codes) != "\x16" {
+		t.Errorf("sent functions % X, want 16", codes)
+	}
+}
+
+func TestMaskWriteFallback(t *testing.T) {
+	regs := []uint16{0x0012}
+	transport := newFakeTransport(registerSlave(regs, false))
+	d := newTestDevice(t, transport, WithMaskWriteFallback())
+	defer d.Close()
+
+	if err := d.MaskWriteRegister(1, 0, 0x00F2, 0x0025); err != nil {
+		t.Fatalf("MaskWriteRegister: %v", err)
+	}
+	if regs[0] != 0x0017 {
+		t.Errorf("register = %04X, want 0017", regs[0])
+	}
+	if err := d.SetBit(1, 0, 15); err != nil {
+		t.Fatalf("SetBit: %v", err)
+	}
+	if regs[0] != 0x8017 {
+		t.Errorf("register = %04X, want 8017", regs[0])
+	}
+	// The slave is remembered, so the second call reads and writes at once
+	if codes := functionCodes(transport.sent()); string(codes) != "\x16\x03\x06\x03\x06" {
+		t.Errorf("sent functions % X, want 16 03 06 03 06", codes)
+	}
+}
+
+func TestMaskWriteWithoutFallback(t *testing.T) {
+	regs := []uint16{0x0012}
+	transport := newFakeTransport(registerSlave(regs, false))
+	d := newTestDevice(t, transport)
+	defer d.Close()
+
+	var exc *ExceptionError
+	if err := d.MaskWriteRegister(1, 0, 0x00F2, 0x0025); !errors.As(err, &exc) || exc.Code != ExceptionIllegalFunction {
+		t.Errorf("MaskWriteRegister error = %v, want Illegal Function", err)
+	}
+	if n := len(transport.sent()); n != 1 || regs[0] != 0x0012 {
+		t.Errorf("sent %d requests and left the register at %04X, want 1 and 0012", n, regs[0])
+	}
+}
+
+func TestMaskWriteFallbackFails(t *testing.T) {
+	// The slave knows neither function 0x16 nor reads
+	transport := newFakeTransport(func(request []byte) []byte {
+		return appendCRC([]byte{request[0], request[1] | 0x80, byte(ExceptionIllegalFunction)})
+	})
+	d := newTestDevice(t, transport, WithMaskWriteFallback())
+	defer d.Close()
+
+	var exc *ExceptionError
+	err := d.MaskWriteRegister(1, 0, 0x00F2, 0x0025)
+	if !errors.As(err, &exc) || exc.FunctionCode != 0x03 {
+		t.Errorf("MaskWriteRegister error = %v, want the exception to the read", err)
+	}
+	// Without the current value there is nothing to write
+	if codes := functionCodes(transport.sent()); string(codes) != "\x16\x03" {
+		t.Errorf("sent functions % X, want 16 03", codes)
+	}
+}
@@ -0,0 +1,96 @@
+//go:build linux
+
+package modbus
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// serialByIDDir holds stable links to USB serial adapters, named after
+// their vendor, product and serial number
+const serialByIDDir = "/dev/serial/by-id"
+
+// sysClassTTY lists every tty together with the device behind it
+const sysClassTTY = "/sys/class/tty"
+
+// serialByIDPath returns the /dev/serial/by-id link to the tty at name, or
+// name itself when there is none, as for on-board UARTs
+func serialByIDPath(name string) string {
+	target, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return name
+	}
+	entries, err := os.ReadDir(serialByIDDir)
+	if err != nil {
+		return name
+	}
+	for _, entry := range entries {
+		link := filepath.Join(serialByIDDir, entry.Name())
+		if t, err := filepath.EvalSymlinks(link); err == nil && t == target {
+			return link
+		}
+	}
+	return name
+}
+
+// findUSBSerialPort returns the tty of the USB adapter matching m
+func findUSBSerialPort(m USBMatch) (string, error) {
+	ttys, err := os.ReadDir(sysClassTTY)
+	if err != nil {
+		return "", fmt.Errorf("failed to list serial ports: %v", err)
+	}
+	for _, tty := range ttys {
+		dev, err := filepath.EvalSymlinks(filepath.Join(sysClassTTY, tty.Name(), "device"))
+		if err != nil {
+			// Virtual terminals have no device
+			continue
+		}
+		if usb := usbDeviceDir(dev); usb != "" && usbMatches(usb, m) {
+			return "/dev/" + tty.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("no serial port found for USB device %s", m)
+}
+
+// usbDeviceDir walks up from the sysfs directory of a tty's device to the
+// USB device it belongs to, if any
+func usbDeviceDir(dir string) string {
+	for ; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "idVendor")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+// usbMatches compares the descriptor IDs in a sysfs USB device directory
+// with m
+func usbMatches(dir string, m USBMatch) bool {
+	vendor, err := readSysfsHex(filepath.Join(dir, "idVendor"))
+	if err != nil || vendor != m.VendorID {
+		return false
+	}
+	product, err := readSysfsHex(filepath.Join(dir, "idProduct"))
+	if err != nil || product != m.ProductID {
+		return false
+	}
+	if m.Serial == "" {
+		return true
+	}
+	serial, err := os.ReadFile(filepath.Join(dir, "serial"))
+	return err == nil && strings.TrimSpace(string(serial)) == m.Serial
+}
+
+// readSysfsHex reads a 16-bit ID such as "0403" from a sysfs attribute
+func readSysfsHex(path string) (uint16, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(b)), 16, 16)
+	return uint16(v), err
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package modbus
+
+import "errors"
+
+func serialByIDPath(name string) string { return name }
+
+func findUSBSerialPort(m USBMatch) (string, error) {
+	return "", errors.New("USB port lookup is only supported on Linux")
+}
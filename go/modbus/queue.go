@@ -0,0 +1,86 @@
+package modbus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// busQueue serializes transactions on a half-duplex bus. It grants the bus
+// to one caller at a time, in the order the callers asked for it, which a
+// plain sync.Mutex does not guarantee.
+type busQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiters []chan struct{}
+}
+
+// acquire blocks until the caller owns the bus or ctx is done
+func (q *busQueue) acquire(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	q.waiters = append(q.waiters, ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	for i, w := range q.waiters {
+		if w == ready {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	q.mu.Unlock()
+
+	// The bus was handed over just as ctx was done; pass it on
+	q.release()
+	return ctx.Err()
+}
+
+// release hands the bus to the longest waiting caller, if any
+func (q *busQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) == 0 {
+		q.busy = false
+		return
+	}
+	next := q.waiters[0]
+	q.waiters = q.waiters[1:]
+	close(next)
+}
+
+// TransactionStats describes one completed request/response exchange
+type TransactionStats struct {
+	SlaveID      byte
+	FunctionCode byte
+
+	// Attempt counts the attempts of a call, starting from 1
+	Attempt int
+
+	// QueueWait is the time spent waiting for other transactions on the
+	// bus; BusTime is the time spent on this one
+	QueueWait time.Duration
+	BusTime   time.Duration
+
+	Err error
+}
+
+// WithStatsHook calls hook after every transaction. The hook runs on the
+// calling goroutine after the bus has been released.
+func WithStatsHook(hook func(TransactionStats)) DeviceOption {
+	return func(c *deviceConfig) {
+		c.statsHook = hook
+	}
+}
@@ -0,0 +1,85 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waiting returns the number of callers queued for the bus
+func (q *busQueue) waiting() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
+
+// isBusy reports whether a caller owns the bus
+func (q *busQueue) isBusy() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.busy
+}
+
+// waitForWaiters polls until n callers are queued
+func waitForWaiters(t *testing.T, q *busQueue, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); q.waiting() != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers queued, want %d", q.waiting(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBusQueueFIFO(t *testing.T) {
+	var q busQueue
+	if err := q.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 5
+	order := make(chan int, callers)
+	for i := range callers {
+		go func() {
+			q.acquire(context.Background())
+			order <- i
+			q.release()
+		}()
+		// Queue the callers one at a time so their order is known
+		waitForWaiters(t, &q, i+1)
+	}
+
+	q.release()
+	for want := range callers {
+		if got := <-order; got != want {
+			t.Fatalf("caller %d got the bus in turn %d", got, want)
+		}
+	}
+	if q.isBusy() {
+		t.Error("bus still busy after every caller released it")
+	}
+}
+
+func TestBusQueueCancel(t *testing.T) {
+	var q busQueue
+	q.acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- q.acquire(ctx) }()
+	waitForWaiters(t, &q, 1)
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("acquire error = %v, want context.Canceled", err)
+	}
+	if n := q.waiting(); n != 0 {
+		t.Errorf("%d callers still queued after cancellation", n)
+	}
+
+	q.release()
+	if q.isBusy() {
+		t.Error("bus still busy after release")
+	}
+}
@@ -0,0 +1,198 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// defaultReconnectInterval is how often a lost port is looked for
+const defaultReconnectInterval = time.Second
+
+// ConnectionState tells whether the device can reach its serial port
+type ConnectionState int32
+
+const (
+	StateConnected ConnectionState = iota
+	StateDisconnected
+	StateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("ConnectionState(%d)", int32(s))
+	}
+}
+
+// USBMatch identifies a USB serial adapter by its descriptor IDs, which do
+// not change when the adapter re-enumerates under another tty name
+type USBMatch struct {
+	VendorID  uint16
+	ProductID uint16
+
+	// Serial is the adapter's serial number; empty matches any
+	Serial string
+}
+
+func (m USBMatch) String() string {
+	if m.Serial == "" {
+		return fmt.Sprintf("%04x:%04x", m.VendorID, m.ProductID)
+	}
+	return fmt.Sprintf("%04x:%04x (serial %s)", m.VendorID, m.ProductID, m.Serial)
+}
+
+// ReconnectConfig makes the device reopen its serial port after an I/O
+// error shows the port is gone, as happens when a USB adapter is unplugged
+// or re-enumerates. Calls made while the port is gone fail at once.
+type ReconnectConfig struct {
+	Enabled bool
+
+	// Interval between attempts to reopen the port; 0 selects 1 s
+	Interval time.Duration
+
+	// ByID reopens the port through its /dev/serial/by-id link, which
+	// follows the adapter when it comes back as, say, ttyUSB1
+	ByID bool
+
+	// USB finds the port by the IDs of its USB adapter instead of by
+	// name, both when the device is created and when it reconnects
+	USB *USBMatch
+}
+
+// WithReconnect enables reopening the serial port when it is lost. It has
+// no effect on transports supplied with WithTransport.
+func WithReconnect(c ReconnectConfig) DeviceOption {
+	return func(cfg *deviceConfig) {
+		cfg.reconnect = c
+	}
+}
+
+// WithStateHook calls hook whenever the connection state changes. The hook
+// runs while the device holds the bus, so it must not call the device.
+func WithStateHook(hook func(ConnectionState)) DeviceOption {
+	return func(c *deviceConfig) {
+		c.stateHook = hook
+	}
+}
+
+// connector opens the serial port of a device, the first time and again
+// after it was lost
+type connector struct {
+	portName  string
+	baudRate  int
+	serial    SerialOptions
+	rs485     RS485Config
+	reconnect ReconnectConfig
+
+	// direction rebuilds a direction controller tied to the port, such as
+	// one driving RTS; nil when the controller outlives the port
+	direction func(Transport) (DirectionController, error)
+}
+
+// open opens the port and applies the settings held by the open file: the
+// line settings and kernel RS-485 mode
+func (c *connector) open() (Transport, error) {
+	portName := c.portName
+	if c.reconnect.USB != nil {
+		var err error
+		if portName, err = findUSBSerialPort(*c.reconnect.USB); err != nil {
+			return nil, err
+		}
+	}
+	transport, err := openSerialTransport(portName, c.baudRate, c.serial)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupTransport(transport, c.rs485); err != nil {
+		return nil, err
+	}
+	return transport, nil
+}
+
+// isDisconnect reports whether err shows the serial port itself is gone
+func isDisconnect(err error) bool {
+	return errors.Is(err, syscall.ENODEV) || errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ENXIO)
+}
+
+// State returns the current connection state
+func (d *ModbusDevice) State() ConnectionState {
+	return ConnectionState(d.state.Load())
+}
+
+// setState records the connection state and reports changes to the hook.
+// The caller must own the bus.
+func (d *ModbusDevice) setState(s ConnectionState) {
+	if ConnectionState(d.state.Swap(int32(s))) != s && d.stateHook != nil {
+		d.stateHook(s)
+	}
+}
+
+// lost handles an I/O error showing the port is gone. With reconnection
+// enabled the port is closed and reopened in the background. The caller
+// must own the bus.
+func (d *ModbusDevice) lost() {
+	d.setState(StateDisconnected)
+	if d.conn == nil || !d.conn.reconnect.Enabled || d.transport == nil {
+		return
+	}
+	d.transport.Close()
+	d.transport = nil
+	if !d.reconnecting {
+		d.reconnecting = true
+		go d.reconnectLoop()
+	}
+}
+
+// reconnect reopens the lost port and restores the transceiver direction.
+// The caller must own the bus.
+func (d *ModbusDevice) reconnect() error {
+	transport, err := d.conn.open()
+	if err != nil {
+		return err
+	}
+	if d.conn.direction != nil {
+		direction, err := d.conn.direction(transport)
+		if err != nil {
+			transport.Close()
+			return err
+		}
+		d.direction.Close()
+		d.direction = direction
+	}
+	if err := d.direction.EnableRX(); err != nil {
+		transport.Close()
+		return fmt.Errorf("failed to enable receive mode: %w", err)
+	}
+
+	d.transport = transport
+	d.staleInput = false
+	d.setState(StateConnected)
+	return nil
+}
+
+// reconnectLoop looks for the lost port until it is back or the device is
+// closed
+func (d *ModbusDevice) reconnectLoop() {
+	for {
+		time.Sleep(d.conn.reconnect.Interval)
+
+		d.queue.acquire(context.Background())
+		done := d.closed || d.transport != nil || d.reconnect() == nil
+		if done {
+			d.reconnecting = false
+		}
+		d.queue.release()
+		if done {
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package modbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallsFailWhileDisconnected(t *testing.T) {
+	_, name := openPTY(t)
+	d, err := NewModbusDevice(name, 9600, 0, 0,
+		WithDirection(nil),
+		WithReconnect(ReconnectConfig{Enabled: true, Interval: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewModbusDevice: %v", err)
+	}
+	defer d.Close()
+
+	d.queue.acquire(context.Background())
+	d.lost()
+	d.queue.release()
+
+	start := time.Now()
+	if _, err := d.ReadHoldingRegisters(1, 0, 1); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("ReadHoldingRegisters error = %v, want ErrDisconnected", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("failed after %v, want at once", elapsed)
+	}
+	if d.State() != StateDisconnected {
+		t.Errorf("State() = %v right after the loss, want disconnected", d.State())
+	}
+
+	// The port is still there, so the reconnect loop finds it again
+	for deadline := time.Now().Add(time.Second); d.State() != StateConnected; {
+		if time.Now().After(deadline) {
+			t.Fatal("port not reopened within 1s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,133 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultBackoffMultiplier grows the backoff between retries
+const defaultBackoffMultiplier = 2
+
+// RetryPolicy decides whether a failed transaction is repeated. By default
+// only timeouts, CRC errors and collisions are retried: an exception
+// response is the slave's answer, and repeating the request will not change
+// it. Writes are only retried when RetryWrites is set or the call is marked
+// idempotent, since a write whose response was lost may still have taken
+// effect.
+//
+// The zero value makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one;
+	// 0 selects 1
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. Each further retry
+	// waits Multiplier (0 selects 2) times longer, up to MaxBackoff if set.
+	Backoff    time.Duration
+	Multiplier float64
+	MaxBackoff time.Duration
+
+	// RetryWrites treats every write as idempotent
+	RetryWrites bool
+
+	// Retryable replaces the default choice of retryable errors
+	Retryable func(error) bool
+}
+
+// WithRetryPolicy sets the retry policy used by calls on the device that do
+// not set their own through WithCallOptions
+func WithRetryPolicy(p RetryPolicy) DeviceOption {
+	return func(c *deviceConfig) {
+		c.retry = p
+	}
+}
+
+// withDefaults fills in zero fields and rejects negative settings
+func (p RetryPolicy) withDefaults() (RetryPolicy, error) {
+	if p.MaxAttempts < 0 || p.Backoff < 0 || p.MaxBackoff < 0 {
+		return p, fmt.Errorf("retry settings must not be negative")
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return p, fmt.Errorf("backoff multiplier %g is less than 1", p.Multiplier)
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 1
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaultBackoffMultiplier
+	}
+	return p, nil
+}
+
+// backoff returns the delay before retry number n, counting from 1
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := float64(p.Backoff)
+	for i := 1; i < n; i++ {
+		delay *= p.Multiplier
+		if p.MaxBackoff > 0 && delay >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(delay)
+}
+
+// retryable reports whether err is worth another attempt
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrCRC) || errors.Is(err, ErrCollision)
+}
+
+// CallOptions adjust a single call. They travel in the context passed to
+// the Context methods, see WithCallOptions.
+type CallOptions struct {
+	// Retry replaces the device's retry policy when not nil
+	Retry *RetryPolicy
+
+	// Idempotent marks a write as safe to repeat
+	Idempotent bool
+
+	// Attempts, when not nil, receives the number of attempts the call made
+	Attempts *int
+}
+
+type callOptionsKey struct{}
+
+// WithCallOptions returns a context carrying opts for the calls made with it
+func WithCallOptions(ctx context.Context, opts CallOptions) context.Context {
+	return context.WithValue(ctx, callOptionsKey{}, opts)
+}
+
+// callOptions returns the CallOptions carried by ctx, if any
+func callOptions(ctx context.Context) CallOptions {
+	opts, _ := ctx.Value(callOptionsKey{}).(CallOptions)
+	return opts
+}
+
+// isWriteFunction reports whether a request with function code fc changes
+// the state of the slave
+func isWriteFunction(fc byte) bool {
+	switch fc {
+	case 0x05, 0x06, 0x0F, 0x10, 0x15, 0x16, 0x17:
+		return true
+	}
+	return false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,111 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p, err := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	p, err := RetryPolicy{}.withDefaults()
+	if err != nil || p.MaxAttempts != 1 || p.Multiplier != defaultBackoffMultiplier {
+		t.Errorf("zero policy = %+v, %v; want one attempt and the default multiplier", p, err)
+	}
+	for _, bad := range []RetryPolicy{{MaxAttempts: -1}, {Backoff: -1}, {MaxBackoff: -1}, {Multiplier: 0.5}} {
+		if _, err := bad.withDefaults(); err == nil {
+			t.Errorf("withDefaults accepted %+v", bad)
+		}
+	}
+}
+
+// flakySlave drops the first failures requests, then answers like slave
+func flakySlave(failures int, slave func([]byte) []byte) func([]byte) []byte {
+	return func(request []byte) []byte {
+		if failures > 0 {
+			failures--
+			return nil
+		}
+		return slave(request)
+	}
+}
+
+func TestRetryTimeouts(t *testing.T) {
+	transport := newFakeTransport(flakySlave(2, holdingRegisters([]uint16{42})))
+	d := newTestDevice(t, transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+	defer d.Close()
+
+	var attempts int
+	ctx := WithCallOptions(context.Background(), CallOptions{Attempts: &attempts})
+	values, err := d.ReadHoldingRegistersContext(ctx, 1, 0, 1)
+	if err != nil || values[0] != 42 {
+		t.Fatalf("ReadHoldingRegisters = %v, %v; want [42]", values, err)
+	}
+	if attempts != 3 || len(transport.sent()) != 3 {
+		t.Errorf("made %d attempts and sent %d requests, want 3", attempts, len(transport.sent()))
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	transport := newFakeTransport(nil)
+	d := newTestDevice(t, transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+	defer d.Close()
+
+	if _, err := d.ReadHoldingRegisters(1, 0, 1); !errors.Is(err, ErrTimeout) {
+		t.Errorf("ReadHoldingRegisters error = %v, want ErrTimeout", err)
+	}
+	if n := len(transport.sent()); n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+}
+
+func TestExceptionsNotRetried(t *testing.T) {
+	transport := newFakeTransport(respond(1, 0x83, 0x04))
+	d := newTestDevice(t, transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	defer d.Close()
+
+	if _, err := d.ReadHoldingRegisters(1, 0, 1); ErrorCode(err) != ModbusExceptionResponse {
+		t.Errorf("ReadHoldingRegisters error = %v, want an exception", err)
+	}
+	if n := len(transport.sent()); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestWritesRetriedOnlyWhenIdempotent(t *testing.T) {
+	transport := newFakeTransport(flakySlave(1, holdingRegisters(nil)))
+	d := newTestDevice(t, transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	defer d.Close()
+
+	if err := d.WriteRegister(1, 0, 1); !errors.Is(err, ErrTimeout) {
+		t.Errorf("WriteRegister error = %v, want ErrTimeout", err)
+	}
+	if n := len(transport.sent()); n != 1 {
+		t.Errorf("sent %d requests for a plain write, want 1", n)
+	}
+
+	transport = newFakeTransport(flakySlave(1, holdingRegisters(nil)))
+	d = newTestDevice(t, transport, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	defer d.Close()
+
+	ctx := WithCallOptions(context.Background(), CallOptions{Idempotent: true})
+	if err := d.WriteRegisterContext(ctx, 1, 0, 1); err != nil {
+		t.Errorf("idempotent WriteRegister error = %v", err)
+	}
+	if n := len(transport.sent()); n != 2 {
+		t.Errorf("sent %d requests for an idempotent write, want 2", n)
+	}
+}
@@ -0,0 +1,37 @@
+package modbus
+
+import "time"
+
+// RS485Config enables the kernel RS-485 mode of a serial port
+// (TIOCSRS485), where the UART driver drives DE from RTS around each
+// transmission and no GPIO direction switching is needed
+type RS485Config struct {
+	Enabled bool
+
+	// RTSActiveLow drives RTS low rather than high while sending, for
+	// boards with an inverter between RTS and DE
+	RTSActiveLow bool
+
+	// DelayBeforeSend and DelayAfterSend are applied by the driver between
+	// asserting RTS and the first bit, and between the last bit and
+	// releasing RTS. The kernel works in whole milliseconds.
+	DelayBeforeSend time.Duration
+	DelayAfterSend  time.Duration
+
+	// RXDuringTX keeps the receiver enabled while sending. The device then
+	// reads back every request as with SerialOptions.LocalEcho.
+	RXDuringTX bool
+}
+
+// rs485Setter is implemented by transports that support kernel RS-485 mode
+type rs485Setter interface {
+	SetRS485(cfg RS485Config) error
+}
+
+// WithRS485 enables kernel RS-485 mode on the serial port. The GPIO
+// direction pins are then left alone.
+func WithRS485(cfg RS485Config) DeviceOption {
+	return func(c *deviceConfig) {
+		c.rs485 = cfg
+	}
+}
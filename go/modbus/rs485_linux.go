@@ -0,0 +1,49 @@
+//go:build linux
+
+package modbus
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Flags of struct serial_rs485 from <linux/serial.h>
+const (
+	serRS485Enabled      = 1 << 0
+	serRS485RTSOnSend    = 1 << 1
+	serRS485RTSAfterSend = 1 << 2
+	serRS485RXDuringTX   = 1 << 4
+)
+
+// serialRS485 mirrors struct serial_rs485
+type serialRS485 struct {
+	Flags              uint32
+	DelayRTSBeforeSend uint32
+	DelayRTSAfterSend  uint32
+	Padding            [5]uint32
+}
+
+// SetRS485 configures the kernel RS-485 mode of the port
+func (p *serialPort) SetRS485(cfg RS485Config) error {
+	var rs serialRS485
+	if cfg.Enabled {
+		rs.Flags |= serRS485Enabled
+		if cfg.RTSActiveLow {
+			rs.Flags |= serRS485RTSAfterSend
+		} else {
+			rs.Flags |= serRS485RTSOnSend
+		}
+		if cfg.RXDuringTX {
+			rs.Flags |= serRS485RXDuringTX
+		}
+		rs.DelayRTSBeforeSend = uint32(cfg.DelayBeforeSend.Milliseconds())
+		rs.DelayRTSAfterSend = uint32(cfg.DelayAfterSend.Milliseconds())
+	}
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(p.fd), unix.TIOCSRS485, uintptr(unsafe.Pointer(&rs)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package modbus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Parity of the serial line
+type Parity int
+
+const (
+	ParityNone Parity = iota
+	ParityEven
+	ParityOdd
+)
+
+// String returns the single-letter name used in line settings like 8E1
+func (p Parity) String() string {
+	switch p {
+	case ParityNone:
+		return "N"
+	case ParityEven:
+		return "E"
+	case ParityOdd:
+		return "O"
+	default:
+		return fmt.Sprintf("Parity(%d)", int(p))
+	}
+}
+
+// ParseParity accepts "N"/"none", "E"/"even" and "O"/"odd" in any case
+func ParseParity(s string) (Parity, error) {
+	switch strings.ToLower(s) {
+	case "n", "none":
+		return ParityNone, nil
+	case "e", "even":
+		return ParityEven, nil
+	case "o", "odd":
+		return ParityOdd, nil
+	}
+	return 0, fmt.Errorf("unknown parity %q", s)
+}
+
+// SerialOptions configures the serial line and response timeouts. The zero
+// value is 8N1 with the default timeouts.
+type SerialOptions struct {
+	// DataBits must be 8 for Modbus RTU; 0 selects 8
+	DataBits int
+
+	Parity Parity
+
+	// StopBits is 1 or 2; 0 selects 1
+	StopBits int
+
+	// ResponseTimeout is how long to wait for the first byte of a response;
+	// 0 selects the default of 5 s
+	ResponseTimeout time.Duration
+
+	// InterCharTimeout is the longest gap accepted between two characters
+	// of a response; 0 selects t1.5 plus the timing slack
+	InterCharTimeout time.Duration
+
+	// LocalEcho is set when the transceiver loops what is sent back to the
+	// receiver, as auto-direction chips and adapters that keep /RE enabled
+	// do. Each request is then read back and checked before the response.
+	LocalEcho bool
+}
+
+// WithSerialOptions sets the line settings and timeouts
+func WithSerialOptions(o SerialOptions) DeviceOption {
+	return func(c *deviceConfig) {
+		c.serial = o
+	}
+}
+
+// withDefaults fills in zero fields and rejects settings RTU cannot use
+func (o SerialOptions) withDefaults() (SerialOptions, error) {
+	if o.DataBits == 0 {
+		o.DataBits = 8
+	}
+	if o.StopBits == 0 {
+		o.StopBits = 1
+	}
+	if o.ResponseTimeout == 0 {
+		o.ResponseTimeout = DefaultResponseTimeout
+	}
+
+	if o.DataBits != 8 {
+		return o, fmt.Errorf("unsupported data bits %d: Modbus RTU requires 8", o.DataBits)
+	}
+	if o.Parity != ParityNone && o.Parity != ParityEven && o.Parity != ParityOdd {
+		return o, fmt.Errorf("unsupported parity %v", o.Parity)
+	}
+	if o.StopBits != 1 && o.StopBits != 2 {
+		return o, fmt.Errorf("unsupported stop bits %d: must be 1 or 2", o.StopBits)
+	}
+	if o.ResponseTimeout < 0 || o.InterCharTimeout < 0 {
+		return o, fmt.Errorf("timeouts must not be negative")
+	}
+	return o, nil
+}
+
+// bitsPerChar counts the start, data, parity and stop bits of a character
+func (o SerialOptions) bitsPerChar() int {
+	bits := 1 + o.DataBits + o.StopBits
+	if o.Parity != ParityNone {
+		bits++
+	}
+	return bits
+}
+
+// String formats the line settings like 8E1
+func (o SerialOptions) String() string {
+	return fmt.Sprintf("%d%v%d", o.DataBits, o.Parity, o.StopBits)
+}
@@ -0,0 +1,202 @@
+//go:build linux
+
+package modbus
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Line status polling after tcdrain. The shift register holds at most one
+// character, so the wait is short even at 1200 baud.
+const (
+	drainLSRTimeout      = 20 * time.Millisecond
+	drainLSRPollInterval = 20 * time.Microsecond
+)
+
+// serialPort is a raw-mode tty. Reads wait with ppoll so deadlines can be
+// as short as the RTU character intervals, which termios VTIME (tenths of
+// a second) cannot express.
+type serialPort struct {
+	fd           int
+	readDeadline time.Time
+}
+
+// openSerialPort opens name at baudRate with the line settings of opts, in
+// raw mode. The port is opened non-blocking so the open does not wait for
+// carrier detect on a modem line; CLOCAL then makes the line ignore it, and
+// blocking mode is restored for writes.
+func openSerialPort(name string, baudRate int, opts SerialOptions) (*serialPort, error) {
+	fd, err := unix.Open(name, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &serialPort{fd: fd}
+	if err := p.configure(baudRate, opts); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	if err := unix.SetNonblock(fd, false); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to clear non-blocking mode: %v", err)
+	}
+	return p, nil
+}
+
+// configure puts the line in raw mode with the given speed and framing.
+// BOTHER lets the kernel accept any baud rate, not just the B* constants.
+func (p *serialPort) configure(baudRate int, opts SerialOptions) error {
+	t, err := unix.IoctlGetTermios(p.fd, unix.TCGETS2)
+	if err != nil {
+		return fmt.Errorf("failed to get line settings: %v", err)
+	}
+
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF | unix.IXANY | unix.INPCK
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CRTSCTS | unix.CBAUD
+	t.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | unix.BOTHER
+	switch opts.Parity {
+	case ParityEven:
+		t.Cflag |= unix.PARENB
+		t.Iflag |= unix.INPCK
+	case ParityOdd:
+		t.Cflag |= unix.PARENB | unix.PARODD
+		t.Iflag |= unix.INPCK
+	}
+	if opts.StopBits == 2 {
+		t.Cflag |= unix.CSTOPB
+	}
+	t.Ispeed = uint32(baudRate)
+	t.Ospeed = uint32(baudRate)
+
+	// Reads never block in the kernel; ppoll does the waiting
+	t.Cc[unix.VMIN] = 0
+	t.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(p.fd, unix.TCSETS2, t); err != nil {
+		return fmt.Errorf("failed to set line settings: %v", err)
+	}
+	return nil
+}
+
+// SetReadDeadline bounds how long Read waits for input
+func (p *serialPort) SetReadDeadline(t time.Time) error {
+	p.readDeadline = t
+	return nil
+}
+
+// Read waits until the read deadline for input, and fails with
+// os.ErrDeadlineExceeded when none arrived
+func (p *serialPort) Read(b []byte) (int, error) {
+	fds := []unix.PollFd{{Fd: int32(p.fd), Events: unix.POLLIN}}
+	for {
+		var timeout *unix.Timespec
+		if !p.readDeadline.IsZero() {
+			ts := unix.NsecToTimespec(max(time.Until(p.readDeadline), 0).Nanoseconds())
+			timeout = &ts
+		}
+		n, err := unix.Ppoll(fds, timeout, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		break
+	}
+
+	for {
+		n, err := unix.Read(p.fd, b)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		// A tty that polls readable but has nothing to read was hung up,
+		// as happens when a USB adapter is unplugged
+		if n == 0 && fds[0].Revents&(unix.POLLHUP|unix.POLLERR|unix.POLLNVAL) != 0 {
+			return 0, unix.EIO
+		}
+		return n, nil
+	}
+}
+
+// Write writes all of b
+func (p *serialPort) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := unix.Write(p.fd, b[written:])
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Drain blocks until the transmitter is empty. tcdrain returns once the
+// kernel buffer is empty, which on some drivers is before the UART shift
+// register has sent the final character, so the line status register is
+// polled for "transmitter empty" afterwards where the driver supports it.
+func (p *serialPort) Drain() error {
+	for {
+		err := unix.IoctlSetInt(p.fd, unix.TCSBRK, 1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	deadline := time.Now().Add(drainLSRTimeout)
+	for {
+		lsr, err := unix.IoctlGetInt(p.fd, unix.TIOCSERGETLSR)
+		if err != nil {
+			// USB adapters and ptys have no line status register
+			return nil
+		}
+		if lsr&unix.TIOCSER_TEMT != 0 || time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(drainLSRPollInterval)
+	}
+}
+
+// SetRTS asserts or clears the RTS modem control line
+func (p *serialPort) SetRTS(asserted bool) error {
+	req := uint(unix.TIOCMBIC)
+	if asserted {
+		req = unix.TIOCMBIS
+	}
+	return unix.IoctlSetPointerInt(p.fd, req, unix.TIOCM_RTS)
+}
+
+// Flush discards any unread input
+func (p *serialPort) Flush() error {
+	return unix.IoctlSetInt(p.fd, unix.TCFLSH, unix.TCIFLUSH)
+}
+
+// Close closes the port. Closing it again fails rather than closing
+// whatever file has reused the descriptor since.
+func (p *serialPort) Close() error {
+	if p.fd < 0 {
+		return os.ErrClosed
+	}
+	err := unix.Close(p.fd)
+	p.fd = -1
+	return err
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY returns the master end of a new pseudo-terminal and the path of
+// its slave end
+func openPTY(t *testing.T) (int, string) {
+	t.Helper()
+	master, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	t.Cleanup(func() { unix.Close(master) })
+	if err := unix.IoctlSetPointerInt(master, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("unlockpt: %v", err)
+	}
+	n, err := unix.IoctlGetInt(master, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("ptsname: %v", err)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestOpenSerialPortBlocking(t *testing.T) {
+	_, name := openPTY(t)
+	p, err := openSerialPort(name, 9600, SerialOptions{})
+	if err != nil {
+		t.Fatalf("openSerialPort: %v", err)
+	}
+	defer p.Close()
+
+	flags, err := unix.FcntlInt(uintptr(p.fd), unix.F_GETFL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&unix.O_NONBLOCK != 0 {
+		t.Error("port left in non-blocking mode")
+	}
+}
+
+func TestSerialPortCloseTwice(t *testing.T) {
+	_, name := openPTY(t)
+	p, err := openSerialPort(name, 9600, SerialOptions{})
+	if err != nil {
+		t.Fatalf("openSerialPort: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := p.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("second Close = %v, want os.ErrClosed", err)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package modbus
+
+import (
+	"errors"
+	"time"
+)
+
+// serialPort is only implemented on Linux
+type serialPort struct{}
+
+func openSerialPort(name string, baudRate int, opts SerialOptions) (*serialPort, error) {
+	return nil, errors.New("serial ports are only supported on Linux")
+}
+
+func (p *serialPort) SetReadDeadline(t time.Time) error { return nil }
+func (p *serialPort) Read(b []byte) (int, error)        { return 0, nil }
+func (p *serialPort) Write(b []byte) (int, error)       { return 0, nil }
+func (p *serialPort) Drain() error                      { return nil }
+func (p *serialPort) Flush() error                      { return nil }
+func (p *serialPort) SetRTS(asserted bool) error        { return nil }
+func (p *serialPort) Close() error                      { return nil }
+
+func (p *serialPort) SetRS485(cfg RS485Config) error { return nil }
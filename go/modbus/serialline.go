@@ -0,0 +1,237 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// busyStatus is the status word of a slave still busy with an earlier
+// program command
+const busyStatus = 0xFFFF
+
+// ReadExceptionStatus reads the eight exception status outputs of the slave
+// (function 0x07). Which conditions they report is up to the device.
+func (d *ModbusDevice) ReadExceptionStatus(slaveID byte) (byte, error) {
+	return d.ReadExceptionStatusContext(context.Background(), slaveID)
+}
+
+// ReadExceptionStatusContext is ReadExceptionStatus with a context bounding
+// the wait for the bus and for the response
+func (d *ModbusDevice) ReadExceptionStatusContext(ctx context.Context, slaveID byte) (byte, error) {
+	response, err := d.sendModbusRequest(ctx, []byte{slaveID, 0x07})
+	if err != nil {
+		return 0, err
+	}
+	return response[2], nil
+}
+
+// CommEventCounter is the answer to Get Comm Event Counter
+type CommEventCounter struct {
+	// Busy is set while the slave is still carrying out an earlier
+	// program command
+	Busy bool `json:"busy"`
+	// EventCount counts the messages the slave completed successfully
+	EventCount uint16 `json:"eventCount"`
+}
+
+// GetCommEventCounter reads the slave's communication event counter
+// (function 0x0B). Comparing it before and after a request tells whether
+// the slave carried the request out.
+func (d *ModbusDevice) GetCommEventCounter(slaveID byte) (CommEventCounter, error) {
+	return d.GetCommEventCounterContext(context.Background(), slaveID)
+}
+
+// GetCommEventCounterContext is GetCommEventCounter with a context bounding
+// the wait for the bus and for the response
+func (d *ModbusDevice) GetCommEventCounterContext(ctx context.Context, slaveID byte) (CommEventCounter, error) {
+	response, err := d.sendModbusRequest(ctx, []byte{slaveID, 0x0B})
+	if err != nil {
+		return CommEventCounter{}, err
+	}
+	return CommEventCounter{
+		Busy:       binary.BigEndian.Uint16(response[2:4]) == busyStatus,
+		EventCount: binary.BigEndian.Uint16(response[4:6]),
+	}, nil
+}
+
+// CommEventKind tells what kind of event a comm event log entry records
+type CommEventKind int
+
+const (
+	CommEventUnknown CommEventKind = iota
+	// CommEventReceive is a message the slave received
+	CommEventReceive
+	// CommEventSend is a response the slave sent
+	CommEventSend
+	// CommEventListenOnly is the slave entering listen only mode
+	CommEventListenOnly
+	// CommEventRestart is a restart of the slave's communications
+	CommEventRestart
+)
+
+// String returns the name the binding and the command line tool use
+func (k CommEventKind) String() string {
+	switch k {
+	case CommEventReceive:
+		return "receive"
+	case CommEventSend:
+		return "send"
+	case CommEventListenOnly:
+		return "listenOnly"
+	case CommEventRestart:
+		return "restart"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the kind by name
+func (k CommEventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// CommEvent is one decoded comm event log entry. The flags that apply
+// depend on Kind; the others are false.
+type CommEvent struct {
+	Kind CommEventKind `json:"kind"`
+	// Raw is the event byte as the slave logged it
+	Raw byte `json:"raw"`
+
+	// CommError means a receive failed its CRC or parity check
+	CommError bool `json:"commError,omitempty"`
+	// CharacterOverrun means characters were lost while receiving
+	CharacterOverrun bool `json:"characterOverrun,omitempty"`
+	// ListenOnly means the slave was in listen only mode
+	ListenOnly bool `json:"listenOnly,omitempty"`
+	// Broadcast means the message received was a broadcast
+	Broadcast bool `json:"broadcast,omitempty"`
+
+	// ReadException means an exception 1 to 3 was sent
+	ReadException bool `json:"readException,omitempty"`
+	// AbortException means a Server Device Failure exception was sent
+	AbortException bool `json:"abortException,omitempty"`
+	// BusyException means an Acknowledge or Server Device Busy exception
+	// was sent
+	BusyException bool `json:"busyException,omitempty"`
+	// NAKException means a Negative Acknowledge exception was sent
+	NAKException bool `json:"nakException,omitempty"`
+	// WriteTimeout means a write timed out
+	WriteTimeout bool `json:"writeTimeout,omitempty"`
+}
+
+// decodeCommEvent decodes an event byte as laid out in the specification
+func decodeCommEvent(b byte) CommEvent {
+	e := CommEvent{Raw: b}
+	switch {
+	case b&0x80 != 0:
+		e.Kind = CommEventReceive
+		e.CommError = b&0x02 != 0
+		e.CharacterOverrun = b&0x10 != 0
+		e.ListenOnly = b&0x20 != 0
+		e.Broadcast = b&0x40 != 0
+	case b&0x40 != 0:
+		e.Kind = CommEventSend
+		e.ReadException = b&0x01 != 0
+		e.AbortException = b&0x02 != 0
+		e.BusyException = b&0x04 != 0
+		e.NAKException = b&0x08 != 0
+		e.WriteTimeout = b&0x10 != 0
+		e.ListenOnly = b&0x20 != 0
+	case b == 0x04:
+		e.Kind = CommEventListenOnly
+	case b == 0x00:
+		e.Kind = CommEventRestart
+	}
+	return e
+}
+
+// CommEventLog is the answer to Get Comm Event Log
+type CommEventLog struct {
+	// Busy is set while the slave is still carrying out an earlier
+	// program command
+	Busy bool `json:"busy"`
+	// EventCount is the counter GetCommEventCounter returns
+	EventCount uint16 `json:"eventCount"`
+	// MessageCount counts the messages the slave has seen on the bus
+	MessageCount uint16 `json:"messageCount"`
+	// Events holds up to 64 events, the most recent first
+	Events []CommEvent `json:"events"`
+}
+
+// commEventLogHeader is the status, event count and message count
+const commEventLogHeader = 6
+
+// GetCommEventLog reads the slave's counters and its log of recent
+// communication events (function 0x0C)
+func (d *ModbusDevice) GetCommEventLog(slaveID byte) (CommEventLog, error) {
+	return d.GetCommEventLogContext(context.Background(), slaveID)
+}
+
+// GetCommEventLogContext is GetCommEventLog with a context bounding the wait
+// for the bus and for the response
+func (d *ModbusDevice) GetCommEventLogContext(ctx context.Context, slaveID byte) (CommEventLog, error) {
+	request := []byte{slaveID, 0x0C}
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return CommEventLog{}, err
+	}
+
+	byteCount := int(response[2])
+	if byteCount < commEventLogHeader {
+		return CommEventLog{}, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected at least %d", byteCount, commEventLogHeader))
+	}
+	log := CommEventLog{
+		Busy:         binary.BigEndian.Uint16(response[3:5]) == busyStatus,
+		EventCount:   binary.BigEndian.Uint16(response[5:7]),
+		MessageCount: binary.BigEndian.Uint16(response[7:9]),
+		Events:       make([]CommEvent, 0, byteCount-commEventLogHeader),
+	}
+	for _, b := range response[3+commEventLogHeader : 3+byteCount] {
+		log.Events = append(log.Events, decodeCommEvent(b))
+	}
+	return log, nil
+}
+
+// runIndicatorOn is the run indicator of a slave that is running
+const runIndicatorOn = 0xFF
+
+// ServerID is the answer to Report Server ID
+type ServerID struct {
+	// ID identifies the kind of device
+	ID byte `json:"id"`
+	// Running is the run indicator: set while the device is running
+	Running bool `json:"running"`
+	// AdditionalData is whatever the device adds, often a model or
+	// firmware string
+	AdditionalData []byte `json:"additionalData"`
+}
+
+// ReportServerID reads the type, run status and device-specific details of
+// the slave (function 0x11). The specification leaves the length of the ID
+// to the device; this takes the common one-byte form.
+func (d *ModbusDevice) ReportServerID(slaveID byte) (ServerID, error) {
+	return d.ReportServerIDContext(context.Background(), slaveID)
+}
+
+// ReportServerIDContext is ReportServerID with a context bounding the wait
+// for the bus and for the response
+func (d *ModbusDevice) ReportServerIDContext(ctx context.Context, slaveID byte) (ServerID, error) {
+	request := []byte{slaveID, 0x11}
+	response, err := d.sendModbusRequest(ctx, request)
+	if err != nil {
+		return ServerID{}, err
+	}
+
+	byteCount := int(response[2])
+	if byteCount < 2 {
+		return ServerID{}, newError(ModbusInvalidResponse, request, response,
+			fmt.Errorf("invalid byte count in response: got %d, expected at least 2", byteCount))
+	}
+	return ServerID{
+		ID:             response[3],
+		Running:        response[4] == runIndicatorOn,
+		AdditionalData: append([]byte(nil), response[5:3+byteCount]...),
+	}, nil
+}
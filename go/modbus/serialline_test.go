@@ -0,0 +1,84 @@
+package modbus
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCommEvent(t *testing.T) {
+	for _, tc := range []struct {
+		raw  byte
+		want CommEvent
+	}{
+		{0x80, CommEvent{Kind: CommEventReceive}},
+		{0xF2, CommEvent{Kind: CommEventReceive, CommError: true, CharacterOverrun: true, ListenOnly: true, Broadcast: true}},
+		{0xC0, CommEvent{Kind: CommEventReceive, Broadcast: true}},
+		{0x40, CommEvent{Kind: CommEventSend}},
+		{0x41, CommEvent{Kind: CommEventSend, ReadException: true}},
+		{0x7F, CommEvent{Kind: CommEventSend, ReadException: true, AbortException: true, BusyException: true,
+			NAKException: true, WriteTimeout: true, ListenOnly: true}},
+		{0x04, CommEvent{Kind: CommEventListenOnly}},
+		{0x00, CommEvent{Kind: CommEventRestart}},
+		{0x01, CommEvent{Kind: CommEventUnknown}},
+	} {
+		tc.want.Raw = tc.raw
+		if got := decodeCommEvent(tc.raw); got != tc.want {
+			t.Errorf("decodeCommEvent(0x%02X) = %+v, want %+v", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestGetCommEventLog(t *testing.T) {
+	// Status, event count 3, message count 5, then a send with a read
+	// exception, a broadcast receive and entering listen only mode
+	d := newTestDevice(t, newFakeTransport(respond(1, 0x0C, 9, 0x00, 0x00, 0x00, 0x03, 0x00, 0x05, 0x41, 0xC0, 0x04)))
+	defer d.Close()
+
+	log, err := d.GetCommEventLog(1)
+	if err != nil {
+		t.Fatalf("GetCommEventLog: %v", err)
+	}
+	want := CommEventLog{EventCount: 3, MessageCount: 5, Events: []CommEvent{
+		{Kind: CommEventSend, Raw: 0x41, ReadException: true},
+		{Kind: CommEventReceive, Raw: 0xC0, Broadcast: true},
+		{Kind: CommEventListenOnly, Raw: 0x04},
+	}}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("GetCommEventLog = %+v, want %+v", log, want)
+	}
+
+	d = newTestDevice(t, newFakeTransport(respond(1, 0x0C, 6, 0xFF, 0xFF, 0x00, 0x03, 0x00, 0x05)))
+	defer d.Close()
+	if log, err := d.GetCommEventLog(1); err != nil || !log.Busy || len(log.Events) != 0 {
+		t.Errorf("GetCommEventLog of a busy slave = %+v, %v; want busy without events", log, err)
+	}
+
+	d = newTestDevice(t, newFakeTransport(respond(1, 0x0C, 4, 0x00, 0x00, 0x00, 0x03)))
+	defer d.Close()
+	if _, err := d.GetCommEventLog(1); ErrorCode(err) != ModbusInvalidResponse {
+		t.Errorf("GetCommEventLog with a short byte count error = %v, want ModbusInvalidResponse", err)
+	}
+}
+
+func TestReportServerID(t *testing.T) {
+	d := newTestDevice(t, newFakeTransport(respond(1, 0x11, 4, 0x2A, runIndicatorOn, 'V', '1')))
+	defer d.Close()
+
+	id, err := d.ReportServerID(1)
+	if err != nil || id.ID != 0x2A || !id.Running || !bytes.Equal(id.AdditionalData, []byte("V1")) {
+		t.Errorf("ReportServerID = %+v, %v; want ID 0x2A, running, additional data V1", id, err)
+	}
+
+	d = newTestDevice(t, newFakeTransport(respond(1, 0x11, 2, 0x2A, 0x00)))
+	defer d.Close()
+	if id, err := d.ReportServerID(1); err != nil || id.Running || len(id.AdditionalData) != 0 {
+		t.Errorf("ReportServerID of a stopped slave = %+v, %v; want not running", id, err)
+	}
+
+	d = newTestDevice(t, newFakeTransport(respond(1, 0x11, 1, 0x2A)))
+	defer d.Close()
+	if _, err := d.ReportServerID(1); ErrorCode(err) != ModbusInvalidResponse {
+		t.Errorf("ReportServerID with a byte count of 1 error = %v, want ModbusInvalidResponse", err)
+	}
+}
@@ -0,0 +1,113 @@
+package modbus
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timing holds the RTU character and frame intervals of a serial line
+type Timing struct {
+	// CharTime is how long one character takes on the wire
+	CharTime time.Duration
+
+	// T15 is the longest silence allowed between two characters of a frame
+	T15 time.Duration
+
+	// T35 is the shortest silence required between two frames
+	T35 time.Duration
+
+	// Slack is added to T15 when receiving, to absorb UART FIFO thresholds,
+	// USB adapter latency and scheduling delays that stretch gaps seen by
+	// the driver
+	Slack time.Duration
+
+	// Turnaround is how long the bus stays quiet after a broadcast, so the
+	// slaves can process it before the next request
+	Turnaround time.Duration
+}
+
+// Above 19200 baud the specification fixes t1.5 and t3.5 instead of
+// scaling them with the character time
+const (
+	fixedTimingBaudRate = 19200
+	fixedT15            = 750 * time.Microsecond
+	fixedT35            = 1750 * time.Microsecond
+
+	defaultTimingSlack = 10 * time.Millisecond
+
+	// The specification suggests 100 to 200ms
+	defaultTurnaround = 100 * time.Millisecond
+)
+
+// NewTiming derives the RTU intervals for baudRate, where bitsPerChar counts
+// the start, data, parity and stop bits of one character
+func NewTiming(baudRate, bitsPerChar int) Timing {
+	charTime := time.Duration(bitsPerChar) * time.Second / time.Duration(baudRate)
+
+	t := Timing{
+		CharTime:   charTime,
+		T15:        charTime * 3 / 2,
+		T35:        charTime * 7 / 2,
+		Slack:      defaultTimingSlack,
+		Turnaround: defaultTurnaround,
+	}
+	if baudRate > fixedTimingBaudRate {
+		t.T15 = fixedT15
+		t.T35 = fixedT35
+	}
+	return t
+}
+
+// withOverrides returns t with every non-zero field of o taking precedence
+func (t Timing) withOverrides(o Timing) Timing {
+	if o.CharTime != 0 {
+		t.CharTime = o.CharTime
+	}
+	if o.T15 != 0 {
+		t.T15 = o.T15
+	}
+	if o.T35 != 0 {
+		t.T35 = o.T35
+	}
+	if o.Slack != 0 {
+		t.Slack = o.Slack
+	}
+	if o.Turnaround != 0 {
+		t.Turnaround = o.Turnaround
+	}
+	return t
+}
+
+// interCharTimeout is how long the receiver waits for the next character of
+// a frame before deciding the frame has ended
+func (t Timing) interCharTimeout() time.Duration {
+	return t.T15 + t.Slack
+}
+
+// waitForSilence sleeps until the bus has been idle for t3.5 since the last
+// frame was sent or received, and for the turnaround after a broadcast
+func (d *ModbusDevice) waitForSilence() {
+	if d.lastActivity.IsZero() {
+		return
+	}
+	until := d.lastActivity.Add(d.timing.T35)
+	if d.quietUntil.After(until) {
+		until = d.quietUntil
+	}
+	if wait := time.Until(until); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// drain waits until the n bytes just written have been transmitted. Without
+// transport support it falls back to their computed airtime.
+func (d *ModbusDevice) drain(n int) error {
+	if dr, ok := d.transport.(drainer); ok {
+		if err := dr.Drain(); err != nil {
+			return fmt.Errorf("failed to drain transmitter: %w", err)
+		}
+		return nil
+	}
+	time.Sleep(time.Duration(n) * d.timing.CharTime)
+	return nil
+}
@@ -0,0 +1,162 @@
+package modbus
+
+import (
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTransport is an in-memory Transport. Every request written to it is
+// passed to slave, and whatever slave returns becomes readable at once.
+type fakeTransport struct {
+	mu       sync.Mutex
+	input    []byte
+	deadline time.Time
+	closed   bool
+	// wake is closed and replaced whenever input, deadline or closed change
+	wake chan struct{}
+
+	slave    func(request []byte) []byte
+	requests [][]byte
+	closes   int
+}
+
+func newFakeTransport(slave func(request []byte) []byte) *fakeTransport {
+	return &fakeTransport{slave: slave, wake: make(chan struct{})}
+}
+
+// notify wakes up a blocked Read; the caller holds t.mu
+func (t *fakeTransport) notify() {
+	close(t.wake)
+	t.wake = make(chan struct{})
+}
+
+func (t *fakeTransport) Read(p []byte) (int, error) {
+	for {
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			return 0, os.ErrClosed
+		}
+		if len(t.input) > 0 {
+			n := copy(p, t.input)
+			t.input = t.input[n:]
+			t.mu.Unlock()
+			return n, nil
+		}
+		deadline, wake := t.deadline, t.wake
+		t.mu.Unlock()
+
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			expired = time.After(wait)
+		}
+		select {
+		case <-wake:
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+func (t *fakeTransport) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return 0, os.ErrClosed
+	}
+	request := append([]byte(nil), p...)
+	t.requests = append(t.requests, request)
+	if t.slave != nil {
+		t.input = append(t.input, t.slave(request)...)
+		t.notify()
+	}
+	return len(p), nil
+}
+
+func (t *fakeTransport) SetReadDeadline(deadline time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deadline = deadline
+	t.notify()
+	return nil
+}
+
+func (t *fakeTransport) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.input = nil
+	return nil
+}
+
+func (t *fakeTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closes++
+	if t.closed {
+		return os.ErrClosed
+	}
+	t.closed = true
+	t.notify()
+	return nil
+}
+
+// sent returns the requests written so far, CRC included
+func (t *fakeTransport) sent() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([][]byte(nil), t.requests...)
+}
+
+// pipeTransport is a Transport over one end of a net.Pipe, whose reads
+// block until the other end writes
+type pipeTransport struct {
+	net.Conn
+}
+
+func (pipeTransport) Flush() error { return nil }
+
+// respond builds a slave that answers every request with body and its CRC
+func respond(body ...byte) func([]byte) []byte {
+	return func([]byte) []byte { return appendCRC(body) }
+}
+
+// holdingRegisters builds a slave that serves Read Holding Registers from
+// regs and echoes Write Single Register requests
+func holdingRegisters(regs []uint16) func([]byte) []byte {
+	return func(request []byte) []byte {
+		switch request[1] {
+		case 0x03:
+			start, count := int(request[2])<<8|int(request[3]), int(request[4])<<8|int(request[5])
+			body := []byte{request[0], 0x03, byte(2 * count)}
+			for _, v := range regs[start : start+count] {
+				body = append(body, byte(v>>8), byte(v))
+			}
+			return appendCRC(body)
+		case 0x06:
+			return request
+		}
+		return nil
+	}
+}
+
+// newTestDevice opens a device on t without direction switching
+func newTestDevice(t *testing.T, transport Transport, opts ...DeviceOption) *ModbusDevice {
+	t.Helper()
+	opts = append([]DeviceOption{
+		WithTransport(transport),
+		WithDirection(nil),
+		WithSerialOptions(SerialOptions{ResponseTimeout: 100 * time.Millisecond}),
+	}, opts...)
+	d, err := NewModbusDevice("fake", 9600, 0, 0, opts...)
+	if err != nil {
+		t.Fatalf("NewModbusDevice: %v", err)
+	}
+	return d
+}
@@ -0,0 +1,81 @@
+package modbus
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Transport is the byte stream a ModbusDevice exchanges frames over
+// (a serial port, a pty, a TCP socket or an in-memory fake).
+//
+// The device times responses out through the read deadline: once it has
+// passed, Read must return, with an error wrapping os.ErrDeadlineExceeded
+// if no data arrived. net.Conn and *os.File on a tty or pty behave this
+// way; a transport that blocks past its deadline stalls the whole device.
+type Transport interface {
+	io.ReadWriteCloser
+
+	// SetReadDeadline bounds the pending and following Reads; the zero
+	// time removes the bound
+	SetReadDeadline(t time.Time) error
+
+	// Flush discards any unread input
+	Flush() error
+}
+
+// DirectionController switches a half-duplex transceiver between
+// transmit and receive
+type DirectionController interface {
+	EnableTX() error
+	EnableRX() error
+	Close() error
+}
+
+// drainer is implemented by transports that can block until everything
+// written has physically left the line
+type drainer interface {
+	Drain() error
+}
+
+// ModbusDevice represents a Modbus RTU device. Its methods may be called
+// from multiple goroutines.
+type ModbusDevice struct {
+	queue     busQueue
+	statsHook func(TransactionStats)
+	stateHook func(ConnectionState)
+	retry     RetryPolicy
+
+	transport Transport
+	direction DirectionController
+	timing    Timing
+
+	responseTimeout  time.Duration
+	interCharTimeout time.Duration
+
+	// localEcho is set when every request comes back to the receiver
+	localEcho bool
+
+	// lastActivity is when the bus last carried a frame
+	lastActivity time.Time
+
+	// quietUntil is when the turnaround after a broadcast ends
+	quietUntil time.Time
+
+	// staleInput is set when a transaction was abandoned mid-response
+	staleInput bool
+
+	// maskWriteFallback enables WithMaskWriteFallback, and noMaskWrite
+	// holds the IDs of slaves found not to support function 0x16
+	maskWriteFallback bool
+	noMaskWrite       sync.Map
+
+	// conn reopens the port after it was lost; nil for transports
+	// supplied by the caller. The transport is nil while the port is gone.
+	conn         *connector
+	state        atomic.Int32
+	reconnecting bool
+
+	closed bool
+}
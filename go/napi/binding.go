@@ -0,0 +1,1612 @@
+//go:build napi
+
+// Package main is the Node.js addon: a thin N-API layer over the modbus
+// package, built with -buildmode=c-shared and the napi tag, which keeps it
+// out of builds of the module on machines without the Node.js headers
+package main
+
+/*
+#include <node_api.h>
+#include <stdint.h>
+#include <string.h>
+#include <stdlib.h>
+
+// Function declarations
+napi_value NewModbusDeviceJS(napi_env env, napi_callback_info info);
+napi_value ReadCoilsJS(napi_env env, napi_callback_info info);
+napi_value ReadDiscreteInputsJS(napi_env env, napi_callback_info info);
+napi_value ReadHoldingRegistersJS(napi_env env, napi_callback_info info);
+napi_value ReadInputRegistersJS(napi_env env, napi_callback_info info);
+napi_value WriteCoilJS(napi_env env, napi_callback_info info);
+napi_value WriteRegisterJS(napi_env env, napi_callback_info info);
+napi_value WriteMultipleCoilsJS(napi_env env, napi_callback_info info);
+napi_value WriteMultipleRegistersJS(napi_env env, napi_callback_info info);
+napi_value ReadWriteMultipleRegistersJS(napi_env env, napi_callback_info info);
+napi_value ReadDeviceIdentificationJS(napi_env env, napi_callback_info info);
+napi_value MaskWriteRegisterJS(napi_env env, napi_callback_info info);
+napi_value SetBitJS(napi_env env, napi_callback_info info);
+napi_value ClearBitJS(napi_env env, napi_callback_info info);
+napi_value ToggleBitJS(napi_env env, napi_callback_info info);
+napi_value ReturnQueryDataJS(napi_env env, napi_callback_info info);
+napi_value RestartCommunicationsJS(napi_env env, napi_callback_info info);
+napi_value DiagnosticRegisterJS(napi_env env, napi_callback_info info);
+napi_value ForceListenOnlyJS(napi_env env, napi_callback_info info);
+napi_value ClearCountersJS(napi_env env, napi_callback_info info);
+napi_value ReadDiagnosticCountersJS(napi_env env, napi_callback_info info);
+napi_value ReadExceptionStatusJS(napi_env env, napi_callback_info info);
+napi_value GetCommEventCounterJS(napi_env env, napi_callback_info info);
+napi_value GetCommEventLogJS(napi_env env, napi_callback_info info);
+napi_value ReportServerIDJS(napi_env env, napi_callback_info info);
+napi_value ReadFileRecordsJS(napi_env env, napi_callback_info info);
+napi_value WriteFileRecordsJS(napi_env env, napi_callback_info info);
+napi_value DownloadFileJS(napi_env env, napi_callback_info info);
+napi_value UploadFileJS(napi_env env, napi_callback_info info);
+napi_value StateJS(napi_env env, napi_callback_info info);
+napi_value AbortJS(napi_env env, napi_callback_info info);
+napi_value CloseJS(napi_env env, napi_callback_info info);
+void completeCallJS(napi_env env, uintptr_t handle, napi_deferred deferred);
+void progressJS(napi_env env, napi_value callback, uintptr_t report);
+void finalizeDeviceJS(uintptr_t* slot);
+void stateChangedJS(napi_env env, napi_value callback, int state);
+
+// Helper function to create function
+static void create_function(napi_env env, napi_value exports, const char* name, napi_callback cb) {
+    napi_value fn;
+    napi_create_function(env, NULL, 0, cb, NULL, &fn);
+    napi_set_named_property(env, exports, name, fn);
+}
+
+// An asynchronous device call: the Go side runs on a goroutine and posts
+// the finished call to a threadsafe function, which settles the promise
+// back on the JS thread. Progress reports travel the same way, so they
+// reach JS in order and before the promise settles.
+typedef struct {
+    napi_deferred deferred;
+    uintptr_t handle;
+} async_call;
+
+static void call_complete(napi_env env, napi_value js_cb, void* context, void* data) {
+    async_call* call = (async_call*)context;
+    if (data != NULL) {
+        if (env != NULL) {
+            progressJS(env, js_cb, (uintptr_t)data);
+        }
+        return;
+    }
+    completeCallJS(env, call->handle, call->deferred);
+}
+
+static void free_call(napi_env env, void* data, void* hint) {
+    free(data);
+}
+
+// Helper function to create the promise of the Go call behind handle and
+// the function it completes through, which also calls progress, if not
+// NULL, with progress reports. Returns NULL if either fails.
+static napi_value queue_call(napi_env env, uintptr_t handle, napi_value progress, napi_threadsafe_function* done) {
+    async_call* call = malloc(sizeof(async_call));
+    call->handle = handle;
+
+    napi_value name;
+    napi_create_string_utf8(env, "modbus", NAPI_AUTO_LENGTH, &name);
+    if (napi_create_threadsafe_function(env, progress, NULL, name, 0, 1, call, free_call, call, call_complete, done) != napi_ok) {
+        free(call);
+        return NULL;
+    }
+
+    napi_value promise;
+    if (napi_create_promise(env, &call->deferred, &promise) != napi_ok) {
+        napi_release_threadsafe_function(*done, napi_tsfn_abort);
+        return NULL;
+    }
+    return promise;
+}
+
+// Helper function to hand a progress report, which must not be 0, to the
+// JS thread
+static void post_progress(napi_threadsafe_function done, uintptr_t report) {
+    napi_call_threadsafe_function(done, (void*)report, napi_tsfn_blocking);
+}
+
+// Helper function to hand a finished call back to the JS thread
+static void post_call(napi_threadsafe_function done) {
+    napi_call_threadsafe_function(done, NULL, napi_tsfn_blocking);
+    napi_release_threadsafe_function(done, napi_tsfn_release);
+}
+
+static void finalize_device(napi_env env, void* data, void* hint) {
+    finalizeDeviceJS((uintptr_t*)data);
+    free(data);
+}
+
+static void call_state(napi_env env, napi_value js_cb, void* context, void* data) {
+    if (env != NULL) {
+        stateChangedJS(env, js_cb, (int)(intptr_t)data);
+    }
+}
+
+// Helper function to create the function connection state changes are
+// posted to from any thread. It does not keep the event loop alive.
+static napi_threadsafe_function create_state_function(napi_env env, napi_value cb) {
+    napi_value name;
+    napi_create_string_utf8(env, "modbus-state", NAPI_AUTO_LENGTH, &name);
+
+    napi_threadsafe_function fn;
+    if (napi_create_threadsafe_function(env, cb, NULL, name, 0, 1, NULL, NULL, NULL, call_state, &fn) != napi_ok) {
+        return NULL;
+    }
+    napi_unref_threadsafe_function(env, fn);
+    return fn;
+}
+
+static void post_state(napi_threadsafe_function fn, int state) {
+    napi_call_threadsafe_function(fn, (void*)(intptr_t)state, napi_tsfn_nonblocking);
+}
+
+// Helper function to wrap a device handle in a JS external
+static napi_value create_device(napi_env env, uintptr_t handle) {
+    uintptr_t* slot = malloc(sizeof(uintptr_t));
+    *slot = handle;
+
+    napi_value result;
+    napi_create_external(env, slot, finalize_device, NULL, &result);
+    return result;
+}
+*/
+import "C"
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math"
+    "runtime/cgo"
+    "sync"
+    "time"
+    "unsafe"
+
+    "github.com/btolarz/max485-raspberry-nodejs/go/modbus"
+)
+
+// asyncCall is a device operation started from JS. run is executed on its
+// own goroutine; its result settles the promise returned to JS.
+type asyncCall struct {
+    id       uint32
+    ctx      context.Context
+    cancel   context.CancelFunc
+    run      func(ctx context.Context, progress func(done, total int)) (interface{}, error)
+    result   interface{}
+    err      error
+    attempts int
+}
+
+// pendingCalls maps the call IDs chosen by index.js to calls in progress,
+// so an AbortSignal can cancel them
+var (
+    pendingMu    sync.Mutex
+    pendingCalls = map[uint32]*asyncCall{}
+)
+
+// jsRetryPolicy mirrors the retry object of the constructor and call options
+type jsRetryPolicy struct {
+    MaxAttempts  int     `json:"maxAttempts"`
+    BackoffMs    int     `json:"backoffMs"`
+    MaxBackoffMs int     `json:"maxBackoffMs"`
+    Multiplier   float64 `json:"multiplier"`
+    RetryWrites  bool    `json:"retryWrites"`
+}
+
+func (p *jsRetryPolicy) policy() modbus.RetryPolicy {
+    return modbus.RetryPolicy{
+        MaxAttempts: p.MaxAttempts,
+        Backoff:     time.Duration(p.BackoffMs) * time.Millisecond,
+        MaxBackoff:  time.Duration(p.MaxBackoffMs) * time.Millisecond,
+        Multiplier:  p.Multiplier,
+        RetryWrites: p.RetryWrites,
+    }
+}
+
+// jsCallOptions mirrors the per-call options object, which index.js passes
+// as a JSON string
+type jsCallOptions struct {
+    TimeoutMs  int            `json:"timeoutMs"`
+    Retry      *jsRetryPolicy `json:"retry"`
+    Idempotent bool           `json:"idempotent"`
+}
+
+// queueCall starts run asynchronously and returns a promise for its result.
+// idArg and optionsArg are the call ID used by Abort and the JSON call
+// options that index.js appends to every call; both may be left out.
+func queueCall(env C.napi_env, idArg, optionsArg C.napi_value, run func(ctx context.Context) (interface{}, error)) C.napi_value {
+    return queueProgressCall(env, idArg, optionsArg, nil, func(ctx context.Context, progress func(done, total int)) (interface{}, error) {
+        return run(ctx)
+    })
+}
+
+// queueProgressCall is queueCall for a call that reports its progress. When
+// progressArg is a function, the progress func passed to run calls it with
+// the work done so far and in total, both below 1<<16; otherwise progress
+// is nil.
+func queueProgressCall(env C.napi_env, idArg, optionsArg, progressArg C.napi_value, run func(ctx context.Context, progress func(done, total int)) (interface{}, error)) C.napi_value {
+    var id C.uint32_t
+    C.napi_get_value_uint32(env, idArg, &id)
+
+    var options jsCallOptions
+    if s := getString(env, optionsArg); s != "" {
+        if err := json.Unmarshal([]byte(s), &options); err != nil {
+            throwError(env, "invalid call options: "+err.Error())
+            return nil
+        }
+    }
+
+    call := &asyncCall{id: uint32(id), run: run}
+    if options.TimeoutMs > 0 {
+        call.ctx, call.cancel = context.WithTimeout(context.Background(), time.Duration(options.TimeoutMs)*time.Millisecond)
+    } else {
+        call.ctx, call.cancel = context.WithCancel(context.Background())
+    }
+    callOpts := modbus.CallOptions{Idempotent: options.Idempotent, Attempts: &call.attempts}
+    if options.Retry != nil {
+        policy := options.Retry.policy()
+        callOpts.Retry = &policy
+    }
+    call.ctx = modbus.WithCallOptions(call.ctx, callOpts)
+
+    var progressType C.napi_valuetype
+    if progressArg != nil && (C.napi_typeof(env, progressArg, &progressType) != C.napi_ok || progressType != C.napi_function) {
+        progressArg = nil
+    }
+
+    handle := cgo.NewHandle(call)
+    var done C.napi_threadsafe_function
+    promise := C.queue_call(env, C.uintptr_t(handle), progressArg, &done)
+    if promise == nil {
+        handle.Delete()
+        call.cancel()
+        throwError(env, "failed to start the call")
+        return nil
+    }
+
+    if call.id != 0 {
+        pendingMu.Lock()
+        pendingCalls[call.id] = call
+        pendingMu.Unlock()
+    }
+    // A call may wait long for the bus; running it on a goroutine keeps it
+    // off the libuv threadpool, which the rest of the process shares
+    var progress func(done, total int)
+    if progressArg != nil {
+        progress = func(n, total int) {
+            C.post_progress(done, C.uintptr_t(n<<16|total))
+        }
+    }
+    go func() {
+        call.result, call.err = call.run(call.ctx, progress)
+        C.post_call(done)
+    }()
+    return promise
+}
+
+// completeCallJS settles the promise of a finished call. It resolves with a
+// JSON object holding the result ("success" for writes) and the number of
+// attempts made. env is NULL when the environment is being torn down.
+//
+//export completeCallJS
+func completeCallJS(env C.napi_env, handle C.uintptr_t, deferred C.napi_deferred) {
+    h := cgo.Handle(handle)
+    call := h.Value().(*asyncCall)
+    h.Delete()
+    call.cancel()
+
+    if call.id != 0 {
+        pendingMu.Lock()
+        delete(pendingCalls, call.id)
+        pendingMu.Unlock()
+    }
+    if env == nil {
+        return
+    }
+
+    if call.err != nil {
+        C.napi_reject_deferred(env, deferred, createError(env, call.err, call.attempts))
+        return
+    }
+    if call.result == nil {
+        call.result = "success"
+    }
+    jsonData, _ := json.Marshal(struct {
+        Result   interface{} `json:"result"`
+        Attempts int         `json:"attempts"`
+    }{call.result, call.attempts})
+    C.napi_resolve_deferred(env, deferred, createString(env, string(jsonData)))
+}
+
+// createString converts s into a JS string
+func createString(env C.napi_env, s string) C.napi_value {
+    cs := C.CString(s)
+    defer C.free(unsafe.Pointer(cs))
+    var result C.napi_value
+    C.napi_create_string_utf8(env, cs, C.size_t(len(s)), &result)
+    return result
+}
+
+// setProperty sets a named property of object
+func setProperty(env C.napi_env, object C.napi_value, name string, value C.napi_value) {
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    C.napi_set_named_property(env, object, cname, value)
+}
+
+// errorCodes gives each error class its code in JS
+var errorCodes = map[modbus.ModbusError]string{
+    modbus.ModbusCRCError:          "MODBUS_CRC_ERROR",
+    modbus.ModbusTimeoutError:      "MODBUS_TIMEOUT",
+    modbus.ModbusInvalidResponse:   "MODBUS_INVALID_RESPONSE",
+    modbus.ModbusSerialError:       "MODBUS_SERIAL_ERROR",
+    modbus.ModbusExceptionResponse: "MODBUS_EXCEPTION",
+    modbus.ModbusCanceled:          "ABORT_ERR",
+    modbus.ModbusInvalidRequest:    "MODBUS_INVALID_REQUEST",
+}
+
+// createError converts err into a JS Error whose code names its class, see
+// errorCodes, carrying the number of attempts made. Calls past their timeout
+// have code ETIMEDOUT rather than MODBUS_TIMEOUT, and aborted calls are
+// AbortErrors. Errors about a request carry slaveId and functionCode, plus
+// the raw response in frame when one was received; exception responses
+// carry the exception code in exceptionCode. Writes whose response does not
+// echo the request have code MODBUS_RESPONSE_MISMATCH and carry the request
+// sent in request.
+func createError(env C.napi_env, err error, attempts int) C.napi_value {
+    code := errorCodes[modbus.ErrorCode(err)]
+    var mismatch *modbus.MismatchError
+    switch {
+    case errors.Is(err, context.DeadlineExceeded):
+        code = "ETIMEDOUT"
+    case errors.As(err, &mismatch):
+        code = "MODBUS_RESPONSE_MISMATCH"
+    }
+
+    var result C.napi_value
+    C.napi_create_error(env, createString(env, code), createString(env, err.Error()), &result)
+
+    var exc *modbus.ExceptionError
+    var modbusErr *modbus.Error
+    switch {
+    case errors.As(err, &exc):
+        setRequestProperties(env, result, exc.SlaveID, exc.FunctionCode, exc.Frame)
+        var exceptionCode C.napi_value
+        C.napi_create_uint32(env, C.uint32_t(exc.Code), &exceptionCode)
+        setProperty(env, result, "exceptionCode", exceptionCode)
+    case errors.As(err, &modbusErr):
+        setRequestProperties(env, result, modbusErr.SlaveID, modbusErr.FunctionCode, modbusErr.Frame)
+    }
+    if mismatch != nil {
+        setProperty(env, result, "request", createBuffer(env, mismatch.Request))
+    }
+    if code == "ABORT_ERR" {
+        setProperty(env, result, "name", createString(env, "AbortError"))
+    }
+    var jsAttempts C.napi_value
+    C.napi_create_uint32(env, C.uint32_t(attempts), &jsAttempts)
+    setProperty(env, result, "attempts", jsAttempts)
+    return result
+}
+
+// setRequestProperties sets the slaveId, functionCode and, if there is one,
+// frame properties of a JS Error
+func setRequestProperties(env C.napi_env, object C.napi_value, slaveID, functionCode byte, frame []byte) {
+    var value C.napi_value
+    C.napi_create_uint32(env, C.uint32_t(slaveID), &value)
+    setProperty(env, object, "slaveId", value)
+    C.napi_create_uint32(env, C.uint32_t(functionCode), &value)
+    setProperty(env, object, "functionCode", value)
+    if len(frame) > 0 {
+        setProperty(env, object, "frame", createBuffer(env, frame))
+    }
+}
+
+// createBuffer copies data, which must not be empty, into a Node.js Buffer
+func createBuffer(env C.napi_env, data []byte) C.napi_value {
+    var result C.napi_value
+    C.napi_create_buffer_copy(env, C.size_t(len(data)), unsafe.Pointer(&data[0]), nil, &result)
+    return result
+}
+
+// throwError throws a JS Error with message msg
+func throwError(env C.napi_env, msg string) {
+    cmsg := C.CString(msg)
+    defer C.free(unsafe.Pointer(cmsg))
+    C.napi_throw_error(env, nil, cmsg)
+}
+
+// argParser reads the arguments of a call. The first invalid one throws a
+// TypeError or RangeError, with the codes Node.js uses, and sets failed;
+// later reads are then skipped.
+type argParser struct {
+    env    C.napi_env
+    failed bool
+}
+
+// fail throws a RangeError about an argument when outOfRange is set and a
+// TypeError otherwise
+func (p *argParser) fail(outOfRange bool, msg string) {
+    cmsg := C.CString(msg)
+    defer C.free(unsafe.Pointer(cmsg))
+    if outOfRange {
+        ccode := C.CString("ERR_OUT_OF_RANGE")
+        defer C.free(unsafe.Pointer(ccode))
+        C.napi_throw_range_error(p.env, ccode, cmsg)
+    } else {
+        ccode := C.CString("ERR_INVALID_ARG_TYPE")
+        defer C.free(unsafe.Pointer(ccode))
+        C.napi_throw_type_error(p.env, ccode, cmsg)
+    }
+    p.failed = true
+}
+
+// integer reads the integer argument name, which must lie between min and
+// max
+func (p *argParser) integer(value C.napi_value, name string, min, max int) int {
+    if p.failed {
+        return 0
+    }
+    var valueType C.napi_valuetype
+    C.napi_typeof(p.env, value, &valueType)
+    if valueType != C.napi_number {
+        p.fail(false, fmt.Sprintf("%s must be a number", name))
+        return 0
+    }
+    var number C.double
+    C.napi_get_value_double(p.env, value, &number)
+    n := float64(number)
+    if n != math.Trunc(n) || n < float64(min) || n > float64(max) {
+        p.fail(true, fmt.Sprintf("%s must be an integer from %d to %d, got %v", name, min, max, n))
+        return 0
+    }
+    return int(n)
+}
+
+// slaveID reads a slave ID, BroadcastID included
+func (p *argParser) slaveID(value C.napi_value) byte {
+    return byte(p.integer(value, "slaveId", modbus.BroadcastID, modbus.MaxSlaveID))
+}
+
+// address reads a data address
+func (p *argParser) address(value C.napi_value, name string) uint16 {
+    return uint16(p.integer(value, name, 0, 0xFFFF))
+}
+
+// boolean reads an argument as JS truthiness
+func (p *argParser) boolean(value C.napi_value) bool {
+    if p.failed {
+        return false
+    }
+    var coerced C.napi_value
+    var result C.bool
+    C.napi_coerce_to_bool(p.env, value, &coerced)
+    C.napi_get_value_bool(p.env, coerced, &result)
+    return bool(result)
+}
+
+// array reads the length of the array argument name, which must hold 1 to
+// max elements
+func (p *argParser) array(value C.napi_value, name string, max int) int {
+    if p.failed {
+        return 0
+    }
+    var isArray C.bool
+    C.napi_is_array(p.env, value, &isArray)
+    if !isArray {
+        p.fail(false, fmt.Sprintf("%s must be an array", name))
+        return 0
+    }
+    var length C.uint32_t
+    C.napi_get_array_length(p.env, value, &length)
+    if length < 1 || int(length) > max {
+        p.fail(true, fmt.Sprintf("%s must hold 1 to %d elements, got %d", name, max, length))
+        return 0
+    }
+    return int(length)
+}
+
+// element returns element i of an array argument
+func (p *argParser) element(array C.napi_value, i int) C.napi_value {
+    var element C.napi_value
+    C.napi_get_element(p.env, array, C.uint32_t(i), &element)
+    return element
+}
+
+// property returns property name of the object argument what
+func (p *argParser) property(object C.napi_value, what, name string) C.napi_value {
+    if p.failed {
+        return nil
+    }
+    var valueType C.napi_valuetype
+    C.napi_typeof(p.env, object, &valueType)
+    if valueType != C.napi_object {
+        p.fail(false, fmt.Sprintf("%s must be an object", what))
+        return nil
+    }
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    var value C.napi_value
+    C.napi_get_named_property(p.env, object, cname, &value)
+    return value
+}
+
+// jsDeviceOptions mirrors the options object accepted by the ModbusRTU
+// constructor, which index.js passes as a JSON string
+type jsDeviceOptions struct {
+    Parity             string `json:"parity"`
+    DataBits           int    `json:"dataBits"`
+    StopBits           int    `json:"stopBits"`
+    ResponseTimeoutMs  int    `json:"responseTimeoutMs"`
+    InterCharTimeoutMs int    `json:"interCharTimeoutMs"`
+    TurnaroundMs       int    `json:"turnaroundMs"`
+    LocalEcho          bool   `json:"localEcho"`
+    MaskWriteFallback  bool   `json:"maskWriteFallback"`
+    GPIOChip           string `json:"gpiochip"`
+    DELine             string `json:"deLine"`
+    RELine             string `json:"reLine"`
+    Direction          *struct {
+        Mode     string `json:"mode"`
+        InvertDE bool   `json:"invertDe"`
+        InvertRE bool   `json:"invertRe"`
+        SetupUs  int    `json:"setupUs"`
+        HoldUs   int    `json:"holdUs"`
+    } `json:"direction"`
+    RS485 *struct {
+        RTSActiveLow      bool `json:"rtsActiveLow"`
+        DelayBeforeSendMs int  `json:"delayBeforeSendMs"`
+        DelayAfterSendMs  int  `json:"delayAfterSendMs"`
+        RXDuringTX        bool `json:"rxDuringTx"`
+    } `json:"rs485"`
+    Retry     *jsRetryPolicy `json:"retry"`
+    Reconnect *struct {
+        IntervalMs int  `json:"intervalMs"`
+        ByID       bool `json:"byId"`
+        USB        *struct {
+            VendorID  uint16 `json:"vendorId"`
+            ProductID uint16 `json:"productId"`
+            Serial    string `json:"serial"`
+        } `json:"usb"`
+    } `json:"reconnect"`
+}
+
+// deviceOptions converts the JS options into DeviceOptions
+func (o *jsDeviceOptions) deviceOptions() ([]modbus.DeviceOption, error) {
+    serial := modbus.SerialOptions{
+        DataBits:         o.DataBits,
+        StopBits:         o.StopBits,
+        ResponseTimeout:  time.Duration(o.ResponseTimeoutMs) * time.Millisecond,
+        InterCharTimeout: time.Duration(o.InterCharTimeoutMs) * time.Millisecond,
+        LocalEcho:        o.LocalEcho,
+    }
+    if o.Parity != "" {
+        var err error
+        if serial.Parity, err = modbus.ParseParity(o.Parity); err != nil {
+            return nil, err
+        }
+    }
+    opts := []modbus.DeviceOption{
+        modbus.WithSerialOptions(serial),
+        modbus.WithTiming(modbus.Timing{Turnaround: time.Duration(o.TurnaroundMs) * time.Millisecond}),
+    }
+    if o.Direction != nil {
+        mode := modbus.DirectionSplit
+        if o.Direction.Mode != "" {
+            var err error
+            if mode, err = modbus.ParseDirectionMode(o.Direction.Mode); err != nil {
+                return nil, err
+            }
+        }
+        opts = append(opts, modbus.WithDirectionConfig(modbus.DirectionConfig{
+            Mode:     mode,
+            InvertDE: o.Direction.InvertDE,
+            InvertRE: o.Direction.InvertRE,
+            Setup:    time.Duration(o.Direction.SetupUs) * time.Microsecond,
+            Hold:     time.Duration(o.Direction.HoldUs) * time.Microsecond,
+        }))
+    }
+    if o.GPIOChip != "" {
+        opts = append(opts, modbus.WithGPIOChip(o.GPIOChip, o.DELine, o.RELine))
+    }
+    if o.RS485 != nil {
+        opts = append(opts, modbus.WithRS485(modbus.RS485Config{
+            Enabled:         true,
+            RTSActiveLow:    o.RS485.RTSActiveLow,
+            DelayBeforeSend: time.Duration(o.RS485.DelayBeforeSendMs) * time.Millisecond,
+            DelayAfterSend:  time.Duration(o.RS485.DelayAfterSendMs) * time.Millisecond,
+            RXDuringTX:      o.RS485.RXDuringTX,
+        }))
+    }
+    if o.Retry != nil {
+        opts = append(opts, modbus.WithRetryPolicy(o.Retry.policy()))
+    }
+    if o.MaskWriteFallback {
+        opts = append(opts, modbus.WithMaskWriteFallback())
+    }
+    if o.Reconnect != nil {
+        reconnect := modbus.ReconnectConfig{
+            Enabled:  true,
+            Interval: time.Duration(o.Reconnect.IntervalMs) * time.Millisecond,
+            ByID:     o.Reconnect.ByID,
+        }
+        if o.Reconnect.USB != nil {
+            reconnect.USB = &modbus.USBMatch{
+                VendorID:  o.Reconnect.USB.VendorID,
+                ProductID: o.Reconnect.USB.ProductID,
+                Serial:    o.Reconnect.USB.Serial,
+            }
+        }
+        opts = append(opts, modbus.WithReconnect(reconnect))
+    }
+    return opts, nil
+}
+
+// getString reads a JS string argument
+func getString(env C.napi_env, value C.napi_value) string {
+    var length C.size_t
+    C.napi_get_value_string_utf8(env, value, nil, 0, &length)
+    buf := make([]C.char, length+1)
+    C.napi_get_value_string_utf8(env, value, &buf[0], length+1, nil)
+    return C.GoString(&buf[0])
+}
+
+// jsDevice is what the JS external of a device refers to
+type jsDevice struct {
+    device *modbus.ModbusDevice
+
+    // stateFn receives connection state changes; nil when no callback
+    // was passed to NewModbusDeviceJS
+    stateFn C.napi_threadsafe_function
+}
+
+// close closes the device, then stops posting state changes to JS
+func (j *jsDevice) close() {
+    j.device.Close()
+    if j.stateFn != nil {
+        C.napi_release_threadsafe_function(j.stateFn, C.napi_tsfn_release)
+    }
+}
+
+// getDevice returns the device behind a JS external created by
+// NewModbusDeviceJS. It throws and returns nil if the device was closed.
+func getDevice(env C.napi_env, value C.napi_value) *modbus.ModbusDevice {
+    var slot unsafe.Pointer
+    C.napi_get_value_external(env, value, &slot)
+    if slot == nil || *(*C.uintptr_t)(slot) == 0 {
+        throwError(env, "device is closed")
+        return nil
+    }
+    return cgo.Handle(*(*C.uintptr_t)(slot)).Value().(*jsDevice).device
+}
+
+// releaseDevice detaches the device from the slot of its JS external. It
+// returns nil if the device was already released.
+func releaseDevice(slot *C.uintptr_t) *jsDevice {
+    if slot == nil || *slot == 0 {
+        return nil
+    }
+    h := cgo.Handle(*slot)
+    *slot = 0
+    device := h.Value().(*jsDevice)
+    h.Delete()
+    return device
+}
+
+//export finalizeDeviceJS
+func finalizeDeviceJS(slot *C.uintptr_t) {
+    // A device garbage collected without close() is closed here, without
+    // blocking the collector on a transaction in progress
+    if device := releaseDevice(slot); device != nil {
+        go device.close()
+    }
+}
+
+//export stateChangedJS
+func stateChangedJS(env C.napi_env, callback C.napi_value, state C.int) {
+    var undefined C.napi_value
+    C.napi_get_undefined(env, &undefined)
+    arg := createString(env, modbus.ConnectionState(state).String())
+    C.napi_call_function(env, undefined, callback, 1, &arg, nil)
+}
+
+// progressJS calls a progress callback with the two counts packed into
+// report by queueProgressCall
+//
+//export progressJS
+func progressJS(env C.napi_env, callback C.napi_value, report C.uintptr_t) {
+    var undefined C.napi_value
+    C.napi_get_undefined(env, &undefined)
+    args := [2]C.napi_value{}
+    C.napi_create_uint32(env, C.uint32_t(report>>16), &args[0])
+    C.napi_create_uint32(env, C.uint32_t(report&0xFFFF), &args[1])
+    C.napi_call_function(env, undefined, callback, 2, &args[0], nil)
+}
+
+// NewModbusDeviceJS opens a device. The optional sixth argument is called
+// with the name of the connection state whenever it changes.
+//
+//export NewModbusDeviceJS
+func NewModbusDeviceJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    portStr := getString(env, args[0])
+
+    var baudRate C.int32_t
+    C.napi_get_value_int32(env, args[1], &baudRate)
+
+    var dePin C.int32_t
+    C.napi_get_value_int32(env, args[2], &dePin)
+
+    var rePin C.int32_t
+    C.napi_get_value_int32(env, args[3], &rePin)
+
+    var options jsDeviceOptions
+    if argc > 4 {
+        if err := json.Unmarshal([]byte(getString(env, args[4])), &options); err != nil {
+            throwError(env, "invalid options: "+err.Error())
+            return nil
+        }
+    }
+    opts, err := options.deviceOptions()
+    if err != nil {
+        throwError(env, "invalid options: "+err.Error())
+        return nil
+    }
+
+    jsd := &jsDevice{}
+    var cbType C.napi_valuetype
+    if argc > 5 && C.napi_typeof(env, args[5], &cbType) == C.napi_ok && cbType == C.napi_function {
+        jsd.stateFn = C.create_state_function(env, args[5])
+        stateFn := jsd.stateFn
+        opts = append(opts, modbus.WithStateHook(func(state modbus.ConnectionState) {
+            C.post_state(stateFn, C.int(state))
+        }))
+    }
+
+    jsd.device, err = modbus.NewModbusDevice(portStr, int(baudRate), int(dePin), int(rePin), opts...)
+    if err != nil {
+        if jsd.stateFn != nil {
+            C.napi_release_threadsafe_function(jsd.stateFn, C.napi_tsfn_release)
+        }
+        throwError(env, err.Error())
+        return nil
+    }
+
+    return C.create_device(env, C.uintptr_t(cgo.NewHandle(jsd)))
+}
+
+// StateJS returns the name of the connection state of a device
+//
+//export StateJS
+func StateJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [1]C.napi_value
+    var argc C.size_t = 1
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    var slot unsafe.Pointer
+    C.napi_get_value_external(env, args[0], &slot)
+    if slot == nil || *(*C.uintptr_t)(slot) == 0 {
+        return createString(env, modbus.StateClosed.String())
+    }
+    device := cgo.Handle(*(*C.uintptr_t)(slot)).Value().(*jsDevice).device
+    return createString(env, device.State().String())
+}
+
+//export ReadCoilsJS
+func ReadCoilsJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    startAddr := p.address(args[2], "startAddr")
+    count := uint16(p.integer(args[3], "count", 1, modbus.MaxReadBits))
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return device.ReadCoilsContext(ctx, slaveID, startAddr, count)
+    })
+}
+
+//export ReadDiscreteInputsJS
+func ReadDiscreteInputsJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    startAddr := p.address(args[2], "startAddr")
+    count := uint16(p.integer(args[3], "count", 1, modbus.MaxReadBits))
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return device.ReadDiscreteInputsContext(ctx, slaveID, startAddr, count)
+    })
+}
+
+//export ReadHoldingRegistersJS
+func ReadHoldingRegistersJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    startAddr := p.address(args[2], "startAddr")
+    count := uint16(p.integer(args[3], "count", 1, modbus.MaxReadRegisters))
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return device.ReadHoldingRegistersContext(ctx, slaveID, startAddr, count)
+    })
+}
+
+//export ReadInputRegistersJS
+func ReadInputRegistersJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    startAddr := p.address(args[2], "startAddr")
+    count := uint16(p.integer(args[3], "count", 1, modbus.MaxReadRegisters))
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return device.ReadInputRegistersContext(ctx, slaveID, startAddr, count)
+    })
+}
+
+//export WriteCoilJS
+func WriteCoilJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    coilAddr := p.address(args[2], "addr")
+    value := p.boolean(args[3])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return nil, device.WriteCoilContext(ctx, slaveID, coilAddr, value)
+    })
+}
+
+//export WriteRegisterJS
+func WriteRegisterJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    regAddr := p.address(args[2], "addr")
+    value := uint16(p.integer(args[3], "value", 0, 0xFFFF))
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return nil, device.WriteRegisterContext(ctx, slaveID, regAddr, value)
+    })
+}
+
+//export ReadWriteMultipleRegistersJS
+func ReadWriteMultipleRegistersJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [8]C.napi_value
+    var argc C.size_t = 8
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    readAddr := p.address(args[2], "readAddr")
+    count := uint16(p.integer(args[3], "count", 1, modbus.MaxReadRegisters))
+    writeAddr := p.address(args[4], "writeAddr")
+    goValues := make([]uint16, p.array(args[5], "values", modbus.MaxReadWriteRegisters))
+    for i := range goValues {
+        goValues[i] = uint16(p.integer(p.element(args[5], i), fmt.Sprintf("values[%d]", i), 0, 0xFFFF))
+    }
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[6], args[7], func(ctx context.Context) (interface{}, error) {
+        return device.ReadWriteMultipleRegistersContext(ctx, slaveID, readAddr, count, writeAddr, goValues)
+    })
+}
+
+//export ReadDeviceIdentificationJS
+func ReadDeviceIdentificationJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    code := modbus.DeviceIDCode(p.integer(args[2], "code", int(modbus.DeviceIDBasic), int(modbus.DeviceIDIndividual)))
+    objectID := byte(p.integer(args[3], "objectId", 0, 0xFF))
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return device.ReadDeviceIdentificationContext(ctx, slaveID, code, objectID)
+    })
+}
+
+//export MaskWriteRegisterJS
+func MaskWriteRegisterJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [7]C.napi_value
+    var argc C.size_t = 7
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    regAddr := p.address(args[2], "addr")
+    andMask := uint16(p.integer(args[3], "andMask", 0, 0xFFFF))
+    orMask := uint16(p.integer(args[4], "orMask", 0, 0xFFFF))
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[5], args[6], func(ctx context.Context) (interface{}, error) {
+        return nil, device.MaskWriteRegisterContext(ctx, slaveID, regAddr, andMask, orMask)
+    })
+}
+
+//export SetBitJS
+func SetBitJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    regAddr := p.address(args[2], "addr")
+    bit := p.integer(args[3], "bit", 0, 15)
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return nil, device.SetBitContext(ctx, slaveID, regAddr, bit)
+    })
+}
+
+//export ClearBitJS
+func ClearBitJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    regAddr := p.address(args[2], "addr")
+    bit := p.integer(args[3], "bit", 0, 15)
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return nil, device.ClearBitContext(ctx, slaveID, regAddr, bit)
+    })
+}
+
+//export ToggleBitJS
+func ToggleBitJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    regAddr := p.address(args[2], "addr")
+    bit := p.integer(args[3], "bit", 0, 15)
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return device.ToggleBitContext(ctx, slaveID, regAddr, bit)
+    })
+}
+
+//export ReturnQueryDataJS
+func ReturnQueryDataJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [5]C.napi_value
+    var argc C.size_t = 5
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    data := make([]byte, p.array(args[2], "data", modbus.MaxDiagnosticData))
+    for i := range data {
+        data[i] = byte(p.integer(p.element(args[2], i), fmt.Sprintf("data[%d]", i), 0, 0xFF))
+    }
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[3], args[4], func(ctx context.Context) (interface{}, error) {
+        return nil, device.ReturnQueryDataContext(ctx, slaveID, data)
+    })
+}
+
+//export RestartCommunicationsJS
+func RestartCommunicationsJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [5]C.napi_value
+    var argc C.size_t = 5
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    clearLog := p.boolean(args[2])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[3], args[4], func(ctx context.Context) (interface{}, error) {
+        return nil, device.RestartCommunicationsContext(ctx, slaveID, clearLog)
+    })
+}
+
+//export DiagnosticRegisterJS
+func DiagnosticRegisterJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        return device.DiagnosticRegisterContext(ctx, slaveID)
+    })
+}
+
+//export ForceListenOnlyJS
+func ForceListenOnlyJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        return nil, device.ForceListenOnlyContext(ctx, slaveID)
+    })
+}
+
+//export ClearCountersJS
+func ClearCountersJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        return nil, device.ClearCountersContext(ctx, slaveID)
+    })
+}
+
+//export ReadDiagnosticCountersJS
+func ReadDiagnosticCountersJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        return device.ReadDiagnosticCountersContext(ctx, slaveID)
+    })
+}
+
+//export ReadExceptionStatusJS
+func ReadExceptionStatusJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        return device.ReadExceptionStatusContext(ctx, slaveID)
+    })
+}
+
+//export GetCommEventCounterJS
+func GetCommEventCounterJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        return device.GetCommEventCounterContext(ctx, slaveID)
+    })
+}
+
+//export GetCommEventLogJS
+func GetCommEventLogJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        return device.GetCommEventLogContext(ctx, slaveID)
+    })
+}
+
+// jsServerID is modbus.ServerID with the additional data as an array of
+// numbers rather than base64
+type jsServerID struct {
+    ID             byte  `json:"id"`
+    Running        bool  `json:"running"`
+    AdditionalData []int `json:"additionalData"`
+}
+
+//export ReportServerIDJS
+func ReportServerIDJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [4]C.napi_value
+    var argc C.size_t = 4
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[2], args[3], func(ctx context.Context) (interface{}, error) {
+        id, err := device.ReportServerIDContext(ctx, slaveID)
+        if err != nil {
+            return nil, err
+        }
+        result := jsServerID{ID: id.ID, Running: id.Running, AdditionalData: make([]int, len(id.AdditionalData))}
+        for i, b := range id.AdditionalData {
+            result.AdditionalData[i] = int(b)
+        }
+        return result, nil
+    })
+}
+
+// maxFileSubRequests is the most sub-requests a file record request holds;
+// the library checks the byte limits of the whole request
+const maxFileSubRequests = 35
+
+//export ReadFileRecordsJS
+func ReadFileRecordsJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [5]C.napi_value
+    var argc C.size_t = 5
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    requests := make([]modbus.FileRecordRequest, p.array(args[2], "requests", maxFileSubRequests))
+    for i := range requests {
+        what := fmt.Sprintf("requests[%d]", i)
+        request := p.element(args[2], i)
+        requests[i] = modbus.FileRecordRequest{
+            File:   uint16(p.integer(p.property(request, what, "file"), what+".file", 1, 0xFFFF)),
+            Record: uint16(p.integer(p.property(request, what, "record"), what+".record", 0, modbus.MaxFileRecordNumber)),
+            Count:  uint16(p.integer(p.property(request, what, "count"), what+".count", 1, modbus.MaxFileReadRegisters)),
+        }
+    }
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[3], args[4], func(ctx context.Context) (interface{}, error) {
+        return device.ReadFileRecordsContext(ctx, slaveID, requests)
+    })
+}
+
+//export WriteFileRecordsJS
+func WriteFileRecordsJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [5]C.napi_value
+    var argc C.size_t = 5
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    records := make([]modbus.FileRecord, p.array(args[2], "records", maxFileSubRequests))
+    for i := range records {
+        what := fmt.Sprintf("records[%d]", i)
+        record := p.element(args[2], i)
+        records[i].File = uint16(p.integer(p.property(record, what, "file"), what+".file", 1, 0xFFFF))
+        records[i].Record = uint16(p.integer(p.property(record, what, "record"), what+".record", 0, modbus.MaxFileRecordNumber))
+        data := p.property(record, what, "data")
+        records[i].Data = make([]uint16, p.array(data, what+".data", modbus.MaxFileWriteRegisters))
+        for j := range records[i].Data {
+            records[i].Data[j] = uint16(p.integer(p.element(data, j), fmt.Sprintf("%s.data[%d]", what, j), 0, 0xFFFF))
+        }
+    }
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[3], args[4], func(ctx context.Context) (interface{}, error) {
+        return nil, device.WriteFileRecordsContext(ctx, slaveID, records)
+    })
+}
+
+// transferOptions reads the optional chunk size of a file transfer whose
+// chunks hold at most limit registers
+func (p *argParser) transferOptions(chunkSize C.napi_value, limit int) modbus.FileTransferOptions {
+    var valueType C.napi_valuetype
+    C.napi_typeof(p.env, chunkSize, &valueType)
+    if valueType == C.napi_undefined {
+        return modbus.FileTransferOptions{}
+    }
+    return modbus.FileTransferOptions{ChunkSize: p.integer(chunkSize, "chunkSize", 1, limit)}
+}
+
+//export DownloadFileJS
+func DownloadFileJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [9]C.napi_value
+    var argc C.size_t = 9
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    file := uint16(p.integer(args[2], "file", 1, 0xFFFF))
+    record := uint16(p.integer(args[3], "record", 0, modbus.MaxFileRecordNumber))
+    count := p.integer(args[4], "count", 1, modbus.MaxFileRecordNumber+1)
+    opts := p.transferOptions(args[5], modbus.MaxFileReadRegisters)
+    if p.failed {
+        return nil
+    }
+
+    return queueProgressCall(env, args[7], args[8], args[6], func(ctx context.Context, progress func(done, total int)) (interface{}, error) {
+        opts.Progress = progress
+        return device.DownloadFileContext(ctx, slaveID, file, record, count, opts)
+    })
+}
+
+//export UploadFileJS
+func UploadFileJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [9]C.napi_value
+    var argc C.size_t = 9
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    file := uint16(p.integer(args[2], "file", 1, 0xFFFF))
+    record := uint16(p.integer(args[3], "record", 0, modbus.MaxFileRecordNumber))
+    data := make([]uint16, p.array(args[4], "data", modbus.MaxFileRecordNumber+1))
+    for i := range data {
+        data[i] = uint16(p.integer(p.element(args[4], i), fmt.Sprintf("data[%d]", i), 0, 0xFFFF))
+    }
+    opts := p.transferOptions(args[5], modbus.MaxFileWriteRegisters)
+    if p.failed {
+        return nil
+    }
+
+    return queueProgressCall(env, args[7], args[8], args[6], func(ctx context.Context, progress func(done, total int)) (interface{}, error) {
+        opts.Progress = progress
+        return nil, device.UploadFileContext(ctx, slaveID, file, record, data, opts)
+    })
+}
+
+//export WriteMultipleCoilsJS
+func WriteMultipleCoilsJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    startAddr := p.address(args[2], "startAddr")
+    goValues := make([]bool, p.array(args[3], "values", modbus.MaxWriteCoils))
+    for i := range goValues {
+        goValues[i] = p.boolean(p.element(args[3], i))
+    }
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return nil, device.WriteMultipleCoilsContext(ctx, slaveID, startAddr, goValues)
+    })
+}
+
+//export WriteMultipleRegistersJS
+func WriteMultipleRegistersJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [6]C.napi_value
+    var argc C.size_t = 6
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    device := getDevice(env, args[0])
+    if device == nil {
+        return nil
+    }
+
+    p := argParser{env: env}
+    slaveID := p.slaveID(args[1])
+    startAddr := p.address(args[2], "startAddr")
+    goValues := make([]uint16, p.array(args[3], "values", modbus.MaxWriteRegisters))
+    for i := range goValues {
+        goValues[i] = uint16(p.integer(p.element(args[3], i), fmt.Sprintf("values[%d]", i), 0, 0xFFFF))
+    }
+    if p.failed {
+        return nil
+    }
+
+    return queueCall(env, args[4], args[5], func(ctx context.Context) (interface{}, error) {
+        return nil, device.WriteMultipleRegistersContext(ctx, slaveID, startAddr, goValues)
+    })
+}
+
+// AbortJS cancels the call with the given ID, if it is still in progress
+//
+//export AbortJS
+func AbortJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [1]C.napi_value
+    var argc C.size_t = 1
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    var id C.uint32_t
+    C.napi_get_value_uint32(env, args[0], &id)
+
+    pendingMu.Lock()
+    call := pendingCalls[uint32(id)]
+    pendingMu.Unlock()
+    if call != nil {
+        call.cancel()
+    }
+    return nil
+}
+
+// CloseJS detaches the device from its JS object, so later calls throw,
+// and closes it off the JS thread: Close waits for the calls queued before
+// it, which may take a while on a busy bus. The promise resolves once the
+// port is closed.
+//
+//export CloseJS
+func CloseJS(env C.napi_env, info C.napi_callback_info) C.napi_value {
+    var args [3]C.napi_value
+    var argc C.size_t = 3
+    C.napi_get_cb_info(env, info, &argc, &args[0], nil, nil)
+
+    var slot unsafe.Pointer
+    C.napi_get_value_external(env, args[0], &slot)
+    device := releaseDevice((*C.uintptr_t)(slot))
+
+    return queueCall(env, args[1], args[2], func(ctx context.Context) (interface{}, error) {
+        if device != nil {
+            device.close()
+        }
+        return nil, nil
+    })
+}
+
+//export Init
+func Init(env C.napi_env, exports C.napi_value) C.napi_value {
+    var modbusDevice C.napi_value
+    C.napi_create_object(env, &modbusDevice)
+
+    C.create_function(env, modbusDevice, C.CString("NewModbusDevice"), (C.napi_callback)(C.NewModbusDeviceJS))
+    C.create_function(env, modbusDevice, C.CString("ReadCoils"), (C.napi_callback)(C.ReadCoilsJS))
+    C.create_function(env, modbusDevice, C.CString("ReadDiscreteInputs"), (C.napi_callback)(C.ReadDiscreteInputsJS))
+    C.create_function(env, modbusDevice, C.CString("ReadHoldingRegisters"), (C.napi_callback)(C.ReadHoldingRegistersJS))
+    C.create_function(env, modbusDevice, C.CString("ReadInputRegisters"), (C.napi_callback)(C.ReadInputRegistersJS))
+    C.create_function(env, modbusDevice, C.CString("WriteCoil"), (C.napi_callback)(C.WriteCoilJS))
+    C.create_function(env, modbusDevice, C.CString("WriteRegister"), (C.napi_callback)(C.WriteRegisterJS))
+    C.create_function(env, modbusDevice, C.CString("WriteMultipleCoils"), (C.napi_callback)(C.WriteMultipleCoilsJS))
+    C.create_function(env, modbusDevice, C.CString("WriteMultipleRegisters"), (C.napi_callback)(C.WriteMultipleRegistersJS))
+    C.create_function(env, modbusDevice, C.CString("ReadWriteMultipleRegisters"), (C.napi_callback)(C.ReadWriteMultipleRegistersJS))
+    C.create_function(env, modbusDevice, C.CString("ReadDeviceIdentification"), (C.napi_callback)(C.ReadDeviceIdentificationJS))
+    C.create_function(env, modbusDevice, C.CString("MaskWriteRegister"), (C.napi_callback)(C.MaskWriteRegisterJS))
+    C.create_function(env, modbusDevice, C.CString("SetBit"), (C.napi_callback)(C.SetBitJS))
+    C.create_function(env, modbusDevice, C.CString("ClearBit"), (C.napi_callback)(C.ClearBitJS))
+    C.create_function(env, modbusDevice, C.CString("ToggleBit"), (C.napi_callback)(C.ToggleBitJS))
+    C.create_function(env, modbusDevice, C.CString("ReturnQueryData"), (C.napi_callback)(C.ReturnQueryDataJS))
+    C.create_function(env, modbusDevice, C.CString("RestartCommunications"), (C.napi_callback)(C.RestartCommunicationsJS))
+    C.create_function(env, modbusDevice, C.CString("DiagnosticRegister"), (C.napi_callback)(C.DiagnosticRegisterJS))
+    C.create_function(env, modbusDevice, C.CString("ForceListenOnly"), (C.napi_callback)(C.ForceListenOnlyJS))
+    C.create_function(env, modbusDevice, C.CString("ClearCounters"), (C.napi_callback)(C.ClearCountersJS))
+    C.create_function(env, modbusDevice, C.CString("ReadDiagnosticCounters"), (C.napi_callback)(C.ReadDiagnosticCountersJS))
+    C.create_function(env, modbusDevice, C.CString("ReadExceptionStatus"), (C.napi_callback)(C.ReadExceptionStatusJS))
+    C.create_function(env, modbusDevice, C.CString("GetCommEventCounter"), (C.napi_callback)(C.GetCommEventCounterJS))
+    C.create_function(env, modbusDevice, C.CString("GetCommEventLog"), (C.napi_callback)(C.GetCommEventLogJS))
+    C.create_function(env, modbusDevice, C.CString("ReportServerID"), (C.napi_callback)(C.ReportServerIDJS))
+    C.create_function(env, modbusDevice, C.CString("ReadFileRecords"), (C.napi_callback)(C.ReadFileRecordsJS))
+    C.create_function(env, modbusDevice, C.CString("WriteFileRecords"), (C.napi_callback)(C.WriteFileRecordsJS))
+    C.create_function(env, modbusDevice, C.CString("DownloadFile"), (C.napi_callback)(C.DownloadFileJS))
+    C.create_function(env, modbusDevice, C.CString("UploadFile"), (C.napi_callback)(C.UploadFileJS))
+    C.create_function(env, modbusDevice, C.CString("State"), (C.napi_callback)(C.StateJS))
+    C.create_function(env, modbusDevice, C.CString("Abort"), (C.napi_callback)(C.AbortJS))
+    C.create_function(env, modbusDevice, C.CString("Close"), (C.napi_callback)(C.CloseJS))
+
+    return modbusDevice
+}
+
+// main is required by -buildmode=c-shared but never called
+func main() {}
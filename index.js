@@ -1,81 +1,223 @@
-const { NewModbusDevice, ReadCoils, ReadDiscreteInputs, ReadHoldingRegisters, ReadInputRegisters, WriteCoil, WriteRegister, WriteMultipleCoils, WriteMultipleRegisters, Close } = require('./build/Release/modbus');
+const EventEmitter = require('events');
+const { NewModbusDevice, ReadCoils, ReadDiscreteInputs, ReadHoldingRegisters, ReadInputRegisters, WriteCoil, WriteRegister, WriteMultipleCoils, WriteMultipleRegisters, ReadWriteMultipleRegisters, ReadDeviceIdentification, MaskWriteRegister, SetBit, ClearBit, ToggleBit, ReturnQueryData, RestartCommunications, DiagnosticRegister, ForceListenOnly, ClearCounters, ReadDiagnosticCounters, ReadExceptionStatus, GetCommEventCounter, GetCommEventLog, ReportServerID, ReadFileRecords, WriteFileRecords, DownloadFile, UploadFile, State, Abort, Close } = require('./build/Release/modbus');
 
-class ModbusRTU {
-    constructor(port, baudRate, dePin, rePin) {
-        this.device = NewModbusDevice(port, baudRate, dePin, rePin);
+// Every binding call takes a call ID and a JSON string of call options after
+// its own arguments; the ID lets an AbortSignal cancel the call through Abort.
+let nextCallId = 1;
+
+function abortError() {
+    const error = new Error('The operation was aborted');
+    error.name = 'AbortError';
+    error.code = 'ABORT_ERR';
+    return error;
+}
+
+// Errors carry a machine-readable error.code and error.attempts; those about
+// a request also carry slaveId, functionCode and the raw response in frame.
+// Aborted calls reject with an AbortError.
+async function call(fn, args, { signal, timeoutMs = 0, retry, idempotent = false, report } = {}) {
+    if (signal && signal.aborted) {
+        throw abortError();
+    }
+    const id = nextCallId;
+    nextCallId = nextCallId >= 0xFFFFFFFF ? 1 : nextCallId + 1;
+
+    const onAbort = () => Abort(id);
+    if (signal) {
+        signal.addEventListener('abort', onAbort, { once: true });
+    }
+    // A callback argument that throws aborts the call, which then rejects
+    // with what it threw
+    let failed = false;
+    let failure;
+    const guarded = args.map((arg) => typeof arg !== 'function' ? arg : (...values) => {
+        if (failed) {
+            return;
+        }
+        try {
+            arg(...values);
+        } catch (error) {
+            failed = true;
+            failure = error;
+            Abort(id);
+        }
+    });
+    try {
+        const { result, attempts } = JSON.parse(await fn(...guarded, id, JSON.stringify({ timeoutMs, retry, idempotent })));
+        if (failed) {
+            throw failure;
+        }
+        if (report) {
+            report.attempts = attempts;
+        }
+        return result;
+    } catch (error) {
+        if (failed) {
+            throw failure;
+        }
+        if (report && error.attempts !== undefined) {
+            report.attempts = error.attempts;
+        }
+        throw error;
+    } finally {
+        if (signal) {
+            signal.removeEventListener('abort', onAbort);
+        }
+    }
+}
+
+// ModbusRTU emits 'state' with 'connected', 'disconnected' or 'closed'
+// whenever the connection to the serial port changes.
+class ModbusRTU extends EventEmitter {
+    constructor(port, baudRate, dePin, rePin, options = {}) {
+        super();
+        // A weak reference lets an unused device be garbage collected
+        // despite the native side holding the state callback
+        const self = new WeakRef(this);
+        const onState = (state) => {
+            const device = self.deref();
+            if (device) {
+                device.emit('state', state);
+            }
+        };
+        this.device = NewModbusDevice(port, baudRate, dePin, rePin, JSON.stringify(options), onState);
         if (!this.device) {
             throw new Error('Failed to create Modbus device');
         }
     }
 
-    async readCoils(slaveID, startAddr, count) {
-        const result = await ReadCoils(this.device, slaveID, startAddr, count);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return JSON.parse(result);
+    get state() {
+        return State(this.device);
     }
 
-    async readDiscreteInputs(slaveID, startAddr, count) {
-        const result = await ReadDiscreteInputs(this.device, slaveID, startAddr, count);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return JSON.parse(result);
+    async readCoils(slaveID, startAddr, count, options) {
+        return call(ReadCoils, [this.device, slaveID, startAddr, count], options);
     }
 
-    async readHoldingRegisters(slaveID, startAddr, count) {
-        const result = await ReadHoldingRegisters(this.device, slaveID, startAddr, count);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return JSON.parse(result);
+    async readDiscreteInputs(slaveID, startAddr, count, options) {
+        return call(ReadDiscreteInputs, [this.device, slaveID, startAddr, count], options);
     }
 
-    async readInputRegisters(slaveID, startAddr, count) {
-        const result = await ReadInputRegisters(this.device, slaveID, startAddr, count);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return JSON.parse(result);
+    async readHoldingRegisters(slaveID, startAddr, count, options) {
+        return call(ReadHoldingRegisters, [this.device, slaveID, startAddr, count], options);
     }
 
-    async writeCoil(slaveID, coilAddr, value) {
-        const result = await WriteCoil(this.device, slaveID, coilAddr, value);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return result;
+    async readInputRegisters(slaveID, startAddr, count, options) {
+        return call(ReadInputRegisters, [this.device, slaveID, startAddr, count], options);
     }
 
-    async writeRegister(slaveID, regAddr, value) {
-        const result = await WriteRegister(this.device, slaveID, regAddr, value);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return result;
+    async writeCoil(slaveID, coilAddr, value, options) {
+        return call(WriteCoil, [this.device, slaveID, coilAddr, value], options);
     }
 
-    async writeMultipleCoils(slaveID, startAddr, values) {
-        const result = await WriteMultipleCoils(this.device, slaveID, startAddr, values);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return result;
+    async writeRegister(slaveID, regAddr, value, options) {
+        return call(WriteRegister, [this.device, slaveID, regAddr, value], options);
     }
 
-    async writeMultipleRegisters(slaveID, startAddr, values) {
-        const result = await WriteMultipleRegisters(this.device, slaveID, startAddr, values);
-        if (result.startsWith('Error:')) {
-            throw new Error(result);
-        }
-        return result;
+    async writeMultipleCoils(slaveID, startAddr, values, options) {
+        return call(WriteMultipleCoils, [this.device, slaveID, startAddr, values], options);
+    }
+
+    async writeMultipleRegisters(slaveID, startAddr, values, options) {
+        return call(WriteMultipleRegisters, [this.device, slaveID, startAddr, values], options);
+    }
+
+    async readWriteMultipleRegisters(slaveID, readAddr, count, writeAddr, values, options) {
+        return call(ReadWriteMultipleRegisters, [this.device, slaveID, readAddr, count, writeAddr, values], options);
+    }
+
+    async readDeviceIdentification(slaveID, code = ModbusRTU.DEVICE_ID_BASIC, objectId = 0, options) {
+        return call(ReadDeviceIdentification, [this.device, slaveID, code, objectId], options);
+    }
+
+    async maskWriteRegister(slaveID, regAddr, andMask, orMask, options) {
+        return call(MaskWriteRegister, [this.device, slaveID, regAddr, andMask, orMask], options);
+    }
+
+    async setBit(slaveID, regAddr, bit, options) {
+        return call(SetBit, [this.device, slaveID, regAddr, bit], options);
+    }
+
+    async clearBit(slaveID, regAddr, bit, options) {
+        return call(ClearBit, [this.device, slaveID, regAddr, bit], options);
+    }
+
+    async toggleBit(slaveID, regAddr, bit, options) {
+        return call(ToggleBit, [this.device, slaveID, regAddr, bit], options);
+    }
+
+    async returnQueryData(slaveID, data, options) {
+        return call(ReturnQueryData, [this.device, slaveID, Array.from(data)], options);
+    }
+
+    async restartCommunications(slaveID, clearLog = false, options) {
+        return call(RestartCommunications, [this.device, slaveID, clearLog], options);
+    }
+
+    async readDiagnosticRegister(slaveID, options) {
+        return call(DiagnosticRegister, [this.device, slaveID], options);
+    }
+
+    async forceListenOnly(slaveID, options) {
+        return call(ForceListenOnly, [this.device, slaveID], options);
+    }
+
+    async clearCounters(slaveID, options) {
+        return call(ClearCounters, [this.device, slaveID], options);
+    }
+
+    async readDiagnosticCounters(slaveID, options) {
+        return call(ReadDiagnosticCounters, [this.device, slaveID], options);
+    }
+
+    async readExceptionStatus(slaveID, options) {
+        return call(ReadExceptionStatus, [this.device, slaveID], options);
+    }
+
+    async getCommEventCounter(slaveID, options) {
+        return call(GetCommEventCounter, [this.device, slaveID], options);
+    }
+
+    async getCommEventLog(slaveID, options) {
+        return call(GetCommEventLog, [this.device, slaveID], options);
+    }
+
+    async reportServerId(slaveID, options) {
+        return call(ReportServerID, [this.device, slaveID], options);
     }
 
-    close() {
-        Close(this.device);
+    async readFileRecords(slaveID, requests, options) {
+        return call(ReadFileRecords, [this.device, slaveID, requests], options);
+    }
+
+    async writeFileRecords(slaveID, records, options) {
+        // Typed arrays and Buffers become plain arrays; anything else is
+        // left for the binding to reject
+        const plain = records.map(({ file, record, data }) => ({ file, record, data: ArrayBuffer.isView(data) ? Array.from(data) : data }));
+        return call(WriteFileRecords, [this.device, slaveID, plain], options);
+    }
+
+    async downloadFile(slaveID, file, record, count, { chunkSize, onProgress, ...options } = {}) {
+        return call(DownloadFile, [this.device, slaveID, file, record, count, chunkSize, onProgress], options);
+    }
+
+    async uploadFile(slaveID, file, record, data, { chunkSize, onProgress, ...options } = {}) {
+        await call(UploadFile, [this.device, slaveID, file, record, Array.from(data), chunkSize, onProgress], options);
+    }
+
+    async close() {
+        await call(Close, [this.device]);
     }
 }
 
-module.exports = ModbusRTU; 
+// Writes to this slave ID go to every slave on the bus, which carry them out
+// without answering
+ModbusRTU.BROADCAST = 0;
+
+// Read device ID codes for readDeviceIdentification
+ModbusRTU.DEVICE_ID_BASIC = 1;
+ModbusRTU.DEVICE_ID_REGULAR = 2;
+ModbusRTU.DEVICE_ID_EXTENDED = 3;
+ModbusRTU.DEVICE_ID_INDIVIDUAL = 4;
 
+module.exports = ModbusRTU;
@@ -4,6 +4,8 @@ async function testModbus() {
     try {
         // Create Modbus device instance
         // Parameters: serial port, baud rate, DE pin, RE pin
+        // Device calls return a promise for a JSON string holding the result
+        // and the number of attempts made
         const device = ModbusRTU.NewModbusDevice("/dev/serial0", 9600, 17, 27);
         
         if (!device) {
@@ -18,92 +20,62 @@ async function testModbus() {
             
             // Test reading coils
             console.log("\nReading coils (0-3):");
-            const coils = ModbusRTU.ReadCoils(device, 21, 0, 4);
+            const coils = await ModbusRTU.ReadCoils(device, 21, 0, 4);
             console.log("Raw data:", coils);
-            if (typeof coils === 'string' && coils.startsWith("Error:")) {
-                throw new Error(coils.substring(6));
-            }
-            console.log("Coils:", JSON.parse(coils));
+            console.log("Coils:", JSON.parse(coils).result);
 
             // Test writing single coil
             console.log("\nWriting coil 0:");
-            const writeCoilResult = ModbusRTU.WriteCoil(device, 21, 0, true);
-            if (typeof writeCoilResult === 'string' && writeCoilResult.startsWith("Error:")) {
-                throw new Error(writeCoilResult.substring(6));
-            }
+            const writeCoilResult = await ModbusRTU.WriteCoil(device, 21, 0, true);
             console.log("Coil 0 written");
 
             // Read coils state after write
             console.log("\nChecking coils state after write:");
-            const coilsAfterWrite = ModbusRTU.ReadCoils(device, 21, 0, 4);
+            const coilsAfterWrite = await ModbusRTU.ReadCoils(device, 21, 0, 4);
             console.log("Raw data:", coilsAfterWrite);
-            if (typeof coilsAfterWrite === 'string' && coilsAfterWrite.startsWith("Error:")) {
-                throw new Error(coilsAfterWrite.substring(6));
-            }
-            console.log("Coils after write:", JSON.parse(coilsAfterWrite));
+            console.log("Coils after write:", JSON.parse(coilsAfterWrite).result);
 
             // Test writing multiple coils
             console.log("\nWriting multiple coils (0-3):");
-            const writeCoilsResult = ModbusRTU.WriteMultipleCoils(device, 21, 0, [true, false, true, false]);
-            if (typeof writeCoilsResult === 'string' && writeCoilsResult.startsWith("Error:")) {
-                throw new Error(writeCoilsResult.substring(6));
-            }
+            const writeCoilsResult = await ModbusRTU.WriteMultipleCoils(device, 21, 0, [true, false, true, false]);
             console.log("Multiple coils written");
 
             // Read coils state after multiple write
             console.log("\nChecking coils state after multiple write:");
-            const coilsAfterMultiWrite = ModbusRTU.ReadCoils(device, 21, 0, 4);
+            const coilsAfterMultiWrite = await ModbusRTU.ReadCoils(device, 21, 0, 4);
             console.log("Raw data:", coilsAfterMultiWrite);
-            if (typeof coilsAfterMultiWrite === 'string' && coilsAfterMultiWrite.startsWith("Error:")) {
-                throw new Error(coilsAfterMultiWrite.substring(6));
-            }
-            console.log("Coils after multiple write:", JSON.parse(coilsAfterMultiWrite));
+            console.log("Coils after multiple write:", JSON.parse(coilsAfterMultiWrite).result);
 
             // ===== REGISTERS TEST =====
             console.log("\n=== Testing Registers ===");
             
             // Test reading registers
             console.log("\nReading registers (0-3):");
-            const registers = ModbusRTU.ReadHoldingRegisters(device, 21, 0, 4);
+            const registers = await ModbusRTU.ReadHoldingRegisters(device, 21, 0, 4);
             console.log("Raw data:", registers);
-            if (typeof registers === 'string' && registers.startsWith("Error:")) {
-                throw new Error(registers.substring(6));
-            }
-            console.log("Registers:", JSON.parse(registers));
+            console.log("Registers:", JSON.parse(registers).result);
 
             // Test writing single register
             console.log("\nWriting register 0 (value 123):");
-            const writeRegisterResult = ModbusRTU.WriteRegister(device, 21, 0, 123);
-            if (typeof writeRegisterResult === 'string' && writeRegisterResult.startsWith("Error:")) {
-                throw new Error(writeRegisterResult.substring(6));
-            }
+            const writeRegisterResult = await ModbusRTU.WriteRegister(device, 21, 0, 123);
             console.log("Register 0 written");
 
             // Read registers state after write
             console.log("\nChecking registers state after write:");
-            const registersAfterWrite = ModbusRTU.ReadHoldingRegisters(device, 21, 0, 4);
+            const registersAfterWrite = await ModbusRTU.ReadHoldingRegisters(device, 21, 0, 4);
             console.log("Raw data:", registersAfterWrite);
-            if (typeof registersAfterWrite === 'string' && registersAfterWrite.startsWith("Error:")) {
-                throw new Error(registersAfterWrite.substring(6));
-            }
-            console.log("Registers after write:", JSON.parse(registersAfterWrite));
+            console.log("Registers after write:", JSON.parse(registersAfterWrite).result);
 
             // Test writing multiple registers
             console.log("\nWriting multiple registers (0-3):");
-            const writeRegistersResult = ModbusRTU.WriteMultipleRegisters(device, 21, 0, [50, 100, 150, 200]);
-            if (typeof writeRegistersResult === 'string' && writeRegistersResult.startsWith("Error:")) {
-                throw new Error(writeRegistersResult.substring(6));
-            }
+            const writeRegistersResult = await ModbusRTU.WriteMultipleRegisters(device, 21, 0, [50, 100, 150, 200]);
             console.log("Multiple registers written");
 
             // Read registers state after multiple write
             console.log("\nChecking registers state after multiple write:");
-            const registersAfterMultiWrite = ModbusRTU.ReadHoldingRegisters(device, 21, 0, 4);
+            const registersAfterMultiWrite = await ModbusRTU.ReadHoldingRegisters(device, 21, 0, 4);
             console.log("Raw data:", registersAfterMultiWrite);
-            if (typeof registersAfterMultiWrite === 'string' && registersAfterMultiWrite.startsWith("Error:")) {
-                throw new Error(registersAfterMultiWrite.substring(6));
-            }
-            console.log("Registers after multiple write:", JSON.parse(registersAfterMultiWrite));
+            console.log("Registers after multiple write:", JSON.parse(registersAfterMultiWrite).result);
 
         } finally {
             // Close connection